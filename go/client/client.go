@@ -0,0 +1,674 @@
+// Package client is a Go client for the eriktestapp HTTP control API, written
+// to match the OpenAPI document served at /openapi.json.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IncrementRequest is the payload of POST /{path} on the "http" listener.
+type IncrementRequest struct {
+	IncrementBy              int `json:"incrementBy"`
+	IncrementByPeriodic      int `json:"incrementByPeriodic,omitempty"`
+	IncrementIntervalSeconds int `json:"incrementIntervalSeconds,omitempty"`
+	// Tenant overrides the client's tenant for this request.
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// PathIncrementRequest is the payload of POST /api/increment.
+type PathIncrementRequest struct {
+	Path string `json:"path"`
+	IncrementRequest
+}
+
+// WorkerRequest is the payload of POST /api/workers and /api/workers/stop.
+type WorkerRequest struct {
+	Tenant          string `json:"tenant,omitempty"`
+	Path            string `json:"path"`
+	IncrementBy     int    `json:"incrementBy,omitempty"`
+	IntervalSeconds int    `json:"intervalSeconds,omitempty"`
+}
+
+// TargetsRequest is the payload of POST /api/targets.
+type TargetsRequest struct {
+	Count int `json:"count"`
+}
+
+// TargetGroup is one entry of GET /sd/targets.
+type TargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// CollectorsRequest is the payload of POST /api/collectors.
+type CollectorsRequest struct {
+	CollectorIDs []string `json:"collectorIds"`
+}
+
+// MetricVariant is a metric whose help, unit and type can be redeclared
+// mid-run. Registry is both its Prometheus registry and its OTLP scope.
+// The info, stateset and gaugehistogram types need OpenMetrics enabled.
+type MetricVariant struct {
+	Registry     string            `json:"registry,omitempty"`
+	Name         string            `json:"name"`
+	Type         string            `json:"type"`
+	Help         string            `json:"help,omitempty"`
+	Unit         string            `json:"unit,omitempty"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	Value        float64           `json:"value"`
+	Created      time.Time         `json:"created,omitzero"`
+	States       []string          `json:"states,omitempty"`
+	State        string            `json:"state,omitempty"`
+	Buckets      []float64         `json:"buckets,omitempty"`
+	Observations []float64         `json:"observations,omitempty"`
+}
+
+// RemoveVariantRequest is the payload of POST /api/variants/remove.
+type RemoveVariantRequest struct {
+	Registry string `json:"registry,omitempty"`
+	Name     string `json:"name"`
+}
+
+// Scrape-time collector timestamp modes.
+const (
+	TimestampsOff    = "off"
+	TimestampsNone   = "none"
+	TimestampsNow    = "now"
+	TimestampsLagged = "lagged"
+	TimestampsFuture = "future"
+)
+
+// ScrapeTimestamps is the mode of the scrape-time collector on /metrics.
+type ScrapeTimestamps struct {
+	Mode          string `json:"mode,omitempty"`
+	OffsetSeconds int    `json:"offsetSeconds,omitempty"`
+}
+
+// Latency distributions a PathProfile can use.
+const (
+	LatencyConstant    = "constant"
+	LatencyUniform     = "uniform"
+	LatencyNormal      = "normal"
+	LatencyLogNormal   = "lognormal"
+	LatencyExponential = "exponential"
+)
+
+// Workload is the simulated behaviour of the increment handler per path,
+// as set with POST /api/workload.
+type Workload struct {
+	Profiles []PathProfile `json:"profiles"`
+}
+
+// PathProfile is the latency, error rate and response size of the paths
+// matching Path, a path.Match pattern.
+type PathProfile struct {
+	Path          string          `json:"path"`
+	Latency       LatencyProfile  `json:"latency,omitzero"`
+	ErrorRate     float64         `json:"errorRate,omitempty"`
+	ErrorStatuses map[int]float64 `json:"errorStatuses,omitempty"`
+	// ResponseBytes pads responses to at least this size, or to a uniform
+	// size up to MaxResponseBytes if that is larger.
+	ResponseBytes    int `json:"responseBytes,omitempty"`
+	MaxResponseBytes int `json:"maxResponseBytes,omitempty"`
+}
+
+// LatencyProfile is a response latency distribution in milliseconds.
+type LatencyProfile struct {
+	Distribution string  `json:"distribution,omitempty"`
+	MeanMs       float64 `json:"meanMs,omitempty"`
+	StddevMs     float64 `json:"stddevMs,omitempty"`
+	MedianMs     float64 `json:"medianMs,omitempty"`
+	P99Ms        float64 `json:"p99Ms,omitempty"`
+	MinMs        float64 `json:"minMs,omitempty"`
+	MaxMs        float64 `json:"maxMs,omitempty"`
+}
+
+// Downstream is the outbound calls increments make, as set with POST
+// /api/downstream.
+type Downstream struct {
+	Calls     []DownstreamCall `json:"calls"`
+	MaxHops   int              `json:"maxHops,omitempty"`
+	TimeoutMs int              `json:"timeoutMs,omitempty"`
+}
+
+// DownstreamCall is a call made for the increments of the series paths
+// matching Path. A URL ending in a slash gets the series path appended.
+type DownstreamCall struct {
+	Path   string `json:"path,omitempty"`
+	URL    string `json:"url"`
+	Method string `json:"method,omitempty"`
+}
+
+// FaultRequest is the payload of POST /api/faults.
+type FaultRequest struct {
+	Kind string `json:"kind"`
+}
+
+// FaultRestart makes the process exit shortly after responding.
+const FaultRestart = "restart"
+
+type MetricInfo struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Source      string `json:"source"`
+	Description string `json:"description"`
+}
+
+type SeriesTotal struct {
+	Tenant  string    `json:"tenant,omitempty"`
+	Path    string    `json:"path"`
+	Total   int64     `json:"total"`
+	Created time.Time `json:"created,omitzero"`
+}
+
+// SeriesRequest is the payload of POST /api/series/reset and
+// /api/series/created.
+type SeriesRequest struct {
+	Tenant  string    `json:"tenant,omitempty"`
+	Path    string    `json:"path"`
+	Created time.Time `json:"created,omitzero"`
+}
+
+type Worker struct {
+	Tenant          string    `json:"tenant,omitempty"`
+	Path            string    `json:"path"`
+	IncrementBy     int       `json:"incrementBy"`
+	IntervalSeconds int       `json:"intervalSeconds"`
+	StartedAt       time.Time `json:"startedAt"`
+	Ticks           int64     `json:"ticks"`
+}
+
+type ScrapeRecord struct {
+	Time        time.Time `json:"time"`
+	RemoteAddr  string    `json:"remoteAddr"`
+	UserAgent   string    `json:"userAgent"`
+	ContentType string    `json:"contentType"`
+	Status      int       `json:"status"`
+	DurationMs  float64   `json:"durationMs"`
+}
+
+type ExportRecord struct {
+	Time       time.Time `json:"time"`
+	Metrics    int       `json:"metrics"`
+	DataPoints int       `json:"dataPoints"`
+	DurationMs float64   `json:"durationMs"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// ReplayOptions are the query parameters of POST /api/replay.
+type ReplayOptions struct {
+	// Format is ReplayFormatJSON or ReplayFormatProto.
+	Format string `json:"-"`
+	// Cadence is original, accelerated or asap.
+	Cadence  string            `json:"cadence,omitempty"`
+	Speed    float64           `json:"speed,omitempty"`
+	Rebase   bool              `json:"rebase,omitempty"`
+	Resource map[string]string `json:"resource,omitempty"`
+	Tenant   string            `json:"tenant,omitempty"`
+	Source   string            `json:"-"`
+}
+
+// Replay file formats.
+const (
+	ReplayFormatJSON  = "json"
+	ReplayFormatProto = "proto"
+)
+
+// ReplayResponse is the response of POST /api/replay.
+type ReplayResponse struct {
+	Source   string `json:"source"`
+	Requests int    `json:"requests"`
+	ReplayOptions
+}
+
+// ControlReplayOptions are the query parameters of POST
+// /api/control/replay.
+type ControlReplayOptions struct {
+	Cadence string  `json:"cadence,omitempty"`
+	Speed   float64 `json:"speed,omitempty"`
+	Faults  bool    `json:"faults,omitempty"`
+	Source  string  `json:"-"`
+}
+
+// ControlReplayResponse is the response of POST /api/control/replay.
+type ControlReplayResponse struct {
+	Source   string `json:"source"`
+	Requests int    `json:"requests"`
+	ControlReplayOptions
+}
+
+type ReplayRecord struct {
+	Source   string    `json:"source"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Requests int       `json:"requests"`
+	Failed   int       `json:"failed"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// InstanceLedger is the response of GET /api/series.
+type InstanceLedger struct {
+	Instance string        `json:"instance"`
+	Process  string        `json:"process,omitempty"`
+	Series   []SeriesTotal `json:"series"`
+}
+
+type FleetPeer struct {
+	Peer     string `json:"peer"`
+	Instance string `json:"instance,omitempty"`
+	Series   int    `json:"series"`
+	Error    string `json:"error,omitempty"`
+}
+
+// FleetTotals is the response of GET /api/fleet/series.
+type FleetTotals struct {
+	Complete bool          `json:"complete"`
+	Peers    []FleetPeer   `json:"peers"`
+	Series   []SeriesTotal `json:"series"`
+}
+
+// ClusterView is the response of GET /api/cluster.
+type ClusterView struct {
+	Self        string    `json:"self"`
+	Coordinator string    `json:"coordinator"`
+	Members     []string  `json:"members"`
+	Targets     int       `json:"targets"`
+	Updated     time.Time `json:"updated"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// State is the response of GET /api/state.
+type State struct {
+	Tenants    []string         `json:"tenants"`
+	Targets    int              `json:"targets"`
+	Collectors []string         `json:"collectors"`
+	Metrics    []MetricInfo     `json:"metrics"`
+	Variants   []MetricVariant  `json:"variants"`
+	Timestamps ScrapeTimestamps `json:"timestamps"`
+	Workload   Workload         `json:"workload"`
+	Downstream Downstream       `json:"downstream"`
+	Series     []SeriesTotal    `json:"series"`
+	Workers    []Worker         `json:"workers"`
+	Scrapes    []ScrapeRecord   `json:"scrapes"`
+	Exports    []ExportRecord   `json:"exports"`
+	Replays    []ReplayRecord   `json:"replays"`
+	// Cluster is nil unless cluster mode is on.
+	Cluster *ClusterView `json:"cluster,omitempty"`
+}
+
+// Error is returned for any non-2xx response.
+type Error struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is the server's Retry-After on 429 responses.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("eriktestapp: %d: %s", e.StatusCode, e.Message)
+}
+
+// Client talks to one eriktestapp instance.
+type Client struct {
+	baseURL      string
+	incrementURL string
+	httpClient   *http.Client
+	tenant       string
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sets the client used for requests. Defaults to
+// http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithIncrementURL sets the base URL of the "http" listener used by
+// IncrementPath. Defaults to the base URL passed to New.
+func WithIncrementURL(url string) Option {
+	return func(c *Client) {
+		c.incrementURL = strings.TrimSuffix(url, "/")
+	}
+}
+
+// WithTenant sends tenant in the X-Scope-OrgID header on every request.
+func WithTenant(tenant string) Option {
+	return func(c *Client) {
+		c.tenant = tenant
+	}
+}
+
+// New returns a client for the control API served at baseURL, e.g.
+// "http://localhost:8080".
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	c.incrementURL = c.baseURL
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// IncrementPath posts req to path on the "http" listener.
+func (c *Client) IncrementPath(ctx context.Context, path string, req IncrementRequest) (*IncrementRequest, error) {
+	var resp IncrementRequest
+	if err := c.do(ctx, http.MethodPost, c.incrementURL+path, req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Increment calls POST /api/increment.
+func (c *Client) Increment(ctx context.Context, req PathIncrementRequest) (*PathIncrementRequest, error) {
+	var resp PathIncrementRequest
+	if err := c.do(ctx, http.MethodPost, c.baseURL+"/api/increment", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// State calls GET /api/state.
+func (c *Client) State(ctx context.Context) (*State, error) {
+	var resp State
+	if err := c.do(ctx, http.MethodGet, c.baseURL+"/api/state", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// StartWorker calls POST /api/workers.
+func (c *Client) StartWorker(ctx context.Context, req WorkerRequest) (*WorkerRequest, error) {
+	var resp WorkerRequest
+	if err := c.do(ctx, http.MethodPost, c.baseURL+"/api/workers", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// StopWorker calls POST /api/workers/stop.
+func (c *Client) StopWorker(ctx context.Context, path string) error {
+	return c.do(ctx, http.MethodPost, c.baseURL+"/api/workers/stop", WorkerRequest{Path: path}, nil)
+}
+
+// TenantMetrics calls GET /metrics/{tenant} and returns the exposition
+// text.
+func (c *Client) TenantMetrics(ctx context.Context, tenant string) (string, error) {
+	var buf bytes.Buffer
+	if err := c.do(ctx, http.MethodGet, c.baseURL+"/metrics/"+url.PathEscape(tenant), nil, &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// ResetSeries calls POST /api/series/reset.
+func (c *Client) ResetSeries(ctx context.Context, path string) (SeriesTotal, error) {
+	var resp SeriesTotal
+	if err := c.do(ctx, http.MethodPost, c.baseURL+"/api/series/reset", SeriesRequest{Path: path}, &resp); err != nil {
+		return SeriesTotal{}, err
+	}
+	return resp, nil
+}
+
+// SetSeriesCreated calls POST /api/series/created.
+func (c *Client) SetSeriesCreated(ctx context.Context, path string, created time.Time) (SeriesTotal, error) {
+	var resp SeriesTotal
+	if err := c.do(ctx, http.MethodPost, c.baseURL+"/api/series/created", SeriesRequest{Path: path, Created: created}, &resp); err != nil {
+		return SeriesTotal{}, err
+	}
+	return resp, nil
+}
+
+// SetTargetCount calls POST /api/targets.
+func (c *Client) SetTargetCount(ctx context.Context, count int) error {
+	return c.do(ctx, http.MethodPost, c.baseURL+"/api/targets", TargetsRequest{Count: count}, nil)
+}
+
+// TargetMetrics calls GET /targets/{n}/metrics and returns the exposition
+// text.
+func (c *Client) TargetMetrics(ctx context.Context, n int) (string, error) {
+	var buf bytes.Buffer
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("%s/targets/%d/metrics", c.baseURL, n), nil, &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// TargetGroups calls GET /sd/targets.
+func (c *Client) TargetGroups(ctx context.Context) ([]TargetGroup, error) {
+	var resp []TargetGroup
+	if err := c.do(ctx, http.MethodGet, c.baseURL+"/sd/targets", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// SetCollectorIDs calls POST /api/collectors.
+func (c *Client) SetCollectorIDs(ctx context.Context, ids []string) error {
+	return c.do(ctx, http.MethodPost, c.baseURL+"/api/collectors", CollectorsRequest{CollectorIDs: ids}, nil)
+}
+
+// CollectorTargets calls GET /jobs/{job}/targets?collector_id= and returns
+// the target groups assigned to collectorID.
+func (c *Client) CollectorTargets(ctx context.Context, job, collectorID string) ([]TargetGroup, error) {
+	var resp []TargetGroup
+	u := c.baseURL + "/jobs/" + url.PathEscape(job) + "/targets?collector_id=" + url.QueryEscape(collectorID)
+	if err := c.do(ctx, http.MethodGet, u, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// DeclareVariant calls POST /api/variants and returns the variant as now
+// exposed.
+func (c *Client) DeclareVariant(ctx context.Context, v MetricVariant) (MetricVariant, error) {
+	var resp MetricVariant
+	if err := c.do(ctx, http.MethodPost, c.baseURL+"/api/variants", v, &resp); err != nil {
+		return MetricVariant{}, err
+	}
+	return resp, nil
+}
+
+// RemoveVariant calls POST /api/variants/remove.
+func (c *Client) RemoveVariant(ctx context.Context, registry, name string) error {
+	return c.do(ctx, http.MethodPost, c.baseURL+"/api/variants/remove", RemoveVariantRequest{Registry: registry, Name: name}, nil)
+}
+
+// VariantMetrics calls GET /variants/{registry}/metrics and returns the
+// exposition text.
+func (c *Client) VariantMetrics(ctx context.Context, registry string) (string, error) {
+	var buf bytes.Buffer
+	if err := c.do(ctx, http.MethodGet, c.baseURL+"/variants/"+url.PathEscape(registry)+"/metrics", nil, &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// SetScrapeTimestamps calls POST /api/timestamps and returns the settings
+// now in effect.
+func (c *Client) SetScrapeTimestamps(ctx context.Context, ts ScrapeTimestamps) (ScrapeTimestamps, error) {
+	var resp ScrapeTimestamps
+	if err := c.do(ctx, http.MethodPost, c.baseURL+"/api/timestamps", ts, &resp); err != nil {
+		return ScrapeTimestamps{}, err
+	}
+	return resp, nil
+}
+
+// SetWorkload calls POST /api/workload and returns the profiles now in
+// effect.
+func (c *Client) SetWorkload(ctx context.Context, wl Workload) (Workload, error) {
+	var resp Workload
+	if err := c.do(ctx, http.MethodPost, c.baseURL+"/api/workload", wl, &resp); err != nil {
+		return Workload{}, err
+	}
+	return resp, nil
+}
+
+// SetDownstream calls POST /api/downstream and returns the settings now in
+// effect.
+func (c *Client) SetDownstream(ctx context.Context, d Downstream) (Downstream, error) {
+	var resp Downstream
+	if err := c.do(ctx, http.MethodPost, c.baseURL+"/api/downstream", d, &resp); err != nil {
+		return Downstream{}, err
+	}
+	return resp, nil
+}
+
+// Flush calls POST /flush, exporting every tenant's metrics now.
+func (c *Client) Flush(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, c.baseURL+"/flush", nil, nil)
+}
+
+// Replay calls POST /api/replay with a recorded OTLP file, which the server
+// replays in the background.
+func (c *Client) Replay(ctx context.Context, file io.Reader, opts ReplayOptions) (ReplayResponse, error) {
+	q := url.Values{}
+	set := func(k, v string) {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	set("format", opts.Format)
+	set("cadence", opts.Cadence)
+	set("tenant", opts.Tenant)
+	set("source", opts.Source)
+	if opts.Speed != 0 {
+		q.Set("speed", strconv.FormatFloat(opts.Speed, 'g', -1, 64))
+	}
+	if opts.Rebase {
+		q.Set("rebase", "true")
+	}
+	for k, v := range opts.Resource {
+		q.Add("resource", k+"="+v)
+	}
+	var resp ReplayResponse
+	if err := c.do(ctx, http.MethodPost, c.baseURL+"/api/replay?"+q.Encode(), file, &resp); err != nil {
+		return ReplayResponse{}, err
+	}
+	return resp, nil
+}
+
+// ReplayControl calls POST /api/control/replay with a control recording,
+// which the server replays in the background.
+func (c *Client) ReplayControl(ctx context.Context, recording io.Reader, opts ControlReplayOptions) (ControlReplayResponse, error) {
+	q := url.Values{}
+	if opts.Cadence != "" {
+		q.Set("cadence", opts.Cadence)
+	}
+	if opts.Speed != 0 {
+		q.Set("speed", strconv.FormatFloat(opts.Speed, 'g', -1, 64))
+	}
+	if opts.Faults {
+		q.Set("faults", "true")
+	}
+	if opts.Source != "" {
+		q.Set("source", opts.Source)
+	}
+	var resp ControlReplayResponse
+	if err := c.do(ctx, http.MethodPost, c.baseURL+"/api/control/replay?"+q.Encode(), recording, &resp); err != nil {
+		return ControlReplayResponse{}, err
+	}
+	return resp, nil
+}
+
+// ControlRecording calls GET /api/control/recording and returns the
+// recording, one JSON record per line.
+func (c *Client) ControlRecording(ctx context.Context) (string, error) {
+	var buf bytes.Buffer
+	if err := c.do(ctx, http.MethodGet, c.baseURL+"/api/control/recording", nil, &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Series calls GET /api/series.
+func (c *Client) Series(ctx context.Context) (InstanceLedger, error) {
+	var resp InstanceLedger
+	if err := c.do(ctx, http.MethodGet, c.baseURL+"/api/series", nil, &resp); err != nil {
+		return InstanceLedger{}, err
+	}
+	return resp, nil
+}
+
+// FleetSeries calls GET /api/fleet/series.
+func (c *Client) FleetSeries(ctx context.Context) (FleetTotals, error) {
+	var resp FleetTotals
+	if err := c.do(ctx, http.MethodGet, c.baseURL+"/api/fleet/series", nil, &resp); err != nil {
+		return FleetTotals{}, err
+	}
+	return resp, nil
+}
+
+// Cluster calls GET /api/cluster.
+func (c *Client) Cluster(ctx context.Context) (ClusterView, error) {
+	var resp ClusterView
+	if err := c.do(ctx, http.MethodGet, c.baseURL+"/api/cluster", nil, &resp); err != nil {
+		return ClusterView{}, err
+	}
+	return resp, nil
+}
+
+// TriggerFault calls POST /api/faults.
+func (c *Client) TriggerFault(ctx context.Context, kind string) error {
+	return c.do(ctx, http.MethodPost, c.baseURL+"/api/faults", FaultRequest{Kind: kind}, nil)
+}
+
+func (c *Client) do(ctx context.Context, method, url string, in, out any) error {
+	var body io.Reader
+	if r, ok := in.(io.Reader); ok {
+		// Sent as is, e.g. a recorded file.
+		body = r
+	} else if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	if _, raw := in.(io.Reader); in != nil && !raw {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.tenant != "" {
+		req.Header.Set("X-Scope-OrgID", c.tenant)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(resp.Body)
+		e := &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			e.RetryAfter = time.Duration(secs) * time.Second
+		}
+		return e
+	}
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if buf, ok := out.(*bytes.Buffer); ok {
+		_, err := buf.ReadFrom(resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"eriktestapp/emitter"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// discardExporter drops every export.
+type discardExporter struct{}
+
+func (discardExporter) Temporality(k sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(k)
+}
+
+func (discardExporter) Aggregation(k sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(k)
+}
+
+func (discardExporter) Export(context.Context, *metricdata.ResourceMetrics) error { return nil }
+func (discardExporter) ForceFlush(context.Context) error                          { return nil }
+func (discardExporter) Shutdown(context.Context) error                            { return nil }
+
+// newTestClient serves an App's "metrics" and "http" listeners on test
+// servers and returns a client for them.
+func newTestClient(t *testing.T, cfg emitter.Config, opts ...Option) *Client {
+	t.Helper()
+	cfg.Listeners = emitter.DefaultListeners()
+	cfg.Tracing.Disabled = true
+	app, err := emitter.New(context.Background(),
+		emitter.WithConfig(cfg),
+		emitter.WithExporter(discardExporter{}),
+		emitter.WithRequestLogging(false),
+		emitter.WithExitFunc(func() {}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = app.Shutdown(context.Background()) })
+	api := httptest.NewServer(app.Handler("metrics"))
+	t.Cleanup(api.Close)
+	increments := httptest.NewServer(app.Handler("http"))
+	t.Cleanup(increments.Close)
+	return New(api.URL, append([]Option{WithIncrementURL(increments.URL)}, opts...)...)
+}
+
+func TestClientRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, emitter.Config{}, WithTenant("acme"))
+
+	if _, err := c.IncrementPath(ctx, "/a", IncrementRequest{IncrementBy: 2}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Increment(ctx, PathIncrementRequest{Path: "/a", IncrementRequest: IncrementRequest{IncrementBy: 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Path != "/a" || resp.IncrementBy != 3 || resp.Tenant != "acme" {
+		t.Errorf("Increment = %+v", resp)
+	}
+	if _, err := c.StartWorker(ctx, WorkerRequest{Path: "/w", IncrementBy: 1, IntervalSeconds: 3600}); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := c.State(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(state.Tenants, "acme") {
+		t.Errorf("tenants = %v, want acme among them", state.Tenants)
+	}
+	if len(state.Workers) != 1 || state.Workers[0].Tenant != "acme" || state.Workers[0].Path != "/w" {
+		t.Errorf("workers = %+v, want acme's /w", state.Workers)
+	}
+	if err := c.StopWorker(ctx, "/w"); err != nil {
+		t.Fatal(err)
+	}
+
+	ledger, err := c.Series(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SeriesTotal{Tenant: "acme", Path: "/a", Total: 5}
+	if i := slices.IndexFunc(ledger.Series, func(s SeriesTotal) bool { return s.Path == "/a" }); i < 0 ||
+		ledger.Series[i].Tenant != want.Tenant || ledger.Series[i].Total != want.Total {
+		t.Errorf("series = %+v, want %+v among them", ledger.Series, want)
+	}
+	reset, err := c.ResetSeries(ctx, "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reset.Total != 0 || reset.Created.IsZero() {
+		t.Errorf("ResetSeries = %+v, want a zero total with a created time", reset)
+	}
+	if _, err := c.TenantMetrics(ctx, "acme"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClientError(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClient(t, emitter.Config{Limits: emitter.LimitsConfig{RequestsPerSecond: 1}})
+
+	var e *Error
+	if err := c.TriggerFault(ctx, "nope"); !errors.As(err, &e) || e.StatusCode != http.StatusBadRequest || e.RetryAfter != 0 {
+		t.Errorf("TriggerFault = %v, want a 400 without Retry-After", err)
+	}
+	if _, err := c.IncrementPath(ctx, "/a", IncrementRequest{IncrementBy: 1}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.IncrementPath(ctx, "/a", IncrementRequest{IncrementBy: 1})
+	if !errors.As(err, &e) || e.StatusCode != http.StatusTooManyRequests || e.RetryAfter != time.Second {
+		t.Errorf("IncrementPath over the rate = %v, want a 429 with Retry-After 1s", err)
+	}
+}
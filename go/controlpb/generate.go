@@ -0,0 +1,5 @@
+// Package controlpb holds the published protos for the gRPC control API and
+// the code generated from them.
+package controlpb
+
+//go:generate protoc -I .. --go_out=.. --go_opt=paths=source_relative --go-grpc_out=.. --go-grpc_opt=paths=source_relative ../controlpb/control.proto
@@ -0,0 +1,132 @@
+package emitter
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+const recentActivitySize = 20
+
+type ScrapeRecord struct {
+	Time        time.Time `json:"time"`
+	RemoteAddr  string    `json:"remoteAddr"`
+	UserAgent   string    `json:"userAgent"`
+	ContentType string    `json:"contentType"`
+	Status      int       `json:"status"`
+	DurationMs  float64   `json:"durationMs"`
+}
+
+type ExportRecord struct {
+	Time       time.Time `json:"time"`
+	Metrics    int       `json:"metrics"`
+	DataPoints int       `json:"dataPoints"`
+	DurationMs float64   `json:"durationMs"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// recentLog keeps the last recentActivitySize entries, newest first.
+type recentLog[T any] struct {
+	mu      sync.Mutex
+	entries []T
+}
+
+func (r *recentLog[T]) add(e T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append([]T{e}, r.entries...)
+	if len(r.entries) > recentActivitySize {
+		r.entries = r.entries[:recentActivitySize]
+	}
+}
+
+func (r *recentLog[T]) snapshot() []T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]T{}, r.entries...)
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// recordScrapes wraps the /metrics handler so every scrape shows up in the
+// dashboard.
+func (a *App) recordScrapes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		a.scrapes.add(ScrapeRecord{
+			Time:        start,
+			RemoteAddr:  r.RemoteAddr,
+			UserAgent:   r.UserAgent(),
+			ContentType: rec.Header().Get("Content-Type"),
+			Status:      rec.status,
+			DurationMs:  float64(time.Since(start).Microseconds()) / 1000,
+		})
+	})
+}
+
+// recordingExporter records the outcome of every OTLP export.
+type recordingExporter struct {
+	sdkmetric.Exporter
+	log *recentLog[ExportRecord]
+}
+
+func (e recordingExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	start := time.Now()
+	err := e.Exporter.Export(ctx, rm)
+	rec := ExportRecord{Time: start, DurationMs: float64(time.Since(start).Microseconds()) / 1000}
+	for _, sm := range rm.ScopeMetrics {
+		rec.Metrics += len(sm.Metrics)
+		for _, m := range sm.Metrics {
+			rec.DataPoints += dataPointCount(m.Data)
+		}
+	}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	e.log.add(rec)
+	return err
+}
+
+func dataPointCount(data metricdata.Aggregation) int {
+	switch d := data.(type) {
+	case metricdata.Sum[int64]:
+		return len(d.DataPoints)
+	case metricdata.Sum[float64]:
+		return len(d.DataPoints)
+	case metricdata.Gauge[int64]:
+		return len(d.DataPoints)
+	case metricdata.Gauge[float64]:
+		return len(d.DataPoints)
+	case metricdata.Histogram[int64]:
+		return len(d.DataPoints)
+	case metricdata.Histogram[float64]:
+		return len(d.DataPoints)
+	case metricdata.ExponentialHistogram[int64]:
+		return len(d.DataPoints)
+	case metricdata.ExponentialHistogram[float64]:
+		return len(d.DataPoints)
+	case metricdata.Summary:
+		return len(d.DataPoints)
+	}
+	return 0
+}
@@ -0,0 +1,467 @@
+package emitter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The JSON control API, mounted under /api/. It drives the same code paths
+// as the catch-all POST handler but takes the series path in the payload,
+// so it can share a listener with other routes.
+
+type pathIncrementRequest struct {
+	Path string `json:"path"`
+	IncrementRequest
+}
+
+type workerRequest struct {
+	Tenant          string `json:"tenant,omitempty"`
+	Path            string `json:"path"`
+	IncrementBy     int    `json:"incrementBy,omitempty"`
+	IntervalSeconds int    `json:"intervalSeconds,omitempty"`
+}
+
+type seriesRequest struct {
+	Tenant string `json:"tenant,omitempty"`
+	Path   string `json:"path"`
+	// Created is only used when overriding the created timestamp.
+	Created time.Time `json:"created,omitzero"`
+}
+
+type targetsRequest struct {
+	Count int `json:"count"`
+}
+
+type collectorsRequest struct {
+	CollectorIDs []string `json:"collectorIds"`
+}
+
+type removeVariantRequest struct {
+	Registry string `json:"registry,omitempty"`
+	Name     string `json:"name"`
+}
+
+type replayResponse struct {
+	Source   string `json:"source"`
+	Requests int    `json:"requests"`
+	ReplayOptions
+}
+
+type controlReplayResponse struct {
+	Source   string `json:"source"`
+	Requests int    `json:"requests"`
+	ControlReplayOptions
+}
+
+type faultRequest struct {
+	Kind string `json:"kind"`
+}
+
+type MetricInfo struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Source      string `json:"source"`
+	Description string `json:"description"`
+}
+
+type WorkerInfo struct {
+	Tenant          string    `json:"tenant,omitempty"`
+	Path            string    `json:"path"`
+	IncrementBy     int       `json:"incrementBy"`
+	IntervalSeconds int       `json:"intervalSeconds"`
+	StartedAt       time.Time `json:"startedAt"`
+	Ticks           int64     `json:"ticks"`
+}
+
+type stateResponse struct {
+	Tenants    []string               `json:"tenants"`
+	Targets    int                    `json:"targets"`
+	Collectors []string               `json:"collectors"`
+	Metrics    []MetricInfo           `json:"metrics"`
+	Variants   []MetricVariant        `json:"variants"`
+	Timestamps ScrapeTimestampsConfig `json:"timestamps"`
+	Workload   WorkloadConfig         `json:"workload"`
+	Downstream DownstreamConfig       `json:"downstream"`
+	Series     []SeriesTotal          `json:"series"`
+	Workers    []WorkerInfo           `json:"workers"`
+	Scrapes    []ScrapeRecord         `json:"scrapes"`
+	Exports    []ExportRecord         `json:"exports"`
+	Replays    []ReplayRecord         `json:"replays"`
+	Cluster    *ClusterView           `json:"cluster,omitempty"`
+}
+
+// faultKinds lists the faults that can be triggered through the API.
+var faultKinds = []string{"restart"}
+
+// Metrics returns the metric definitions the app emits.
+func (a *App) Metrics() []MetricInfo {
+	return []MetricInfo{
+		{Name: promCounterName, Type: "counter", Source: "prometheus", Description: "Running sum of incrementBy values by path"},
+		{Name: otlpSumCounterName, Type: "sum", Source: "otlp", Description: "Running sum of incrementBy values by path"},
+		{Name: collectsCounterName, Type: "counter", Source: "prometheus", Description: "Number of times the scrape-time collector has been collected"},
+		{Name: sampleTimestampName, Type: "gauge", Source: "prometheus", Description: "Timestamp attached to this scrape's samples"},
+	}
+}
+
+// Workers returns the running interval workers sorted by tenant and path.
+func (a *App) Workers() []WorkerInfo {
+	out := []WorkerInfo{}
+	a.workers.all(func(m map[seriesKey]*intervalWorker) {
+		for _, w := range m {
+			out = append(out, WorkerInfo{
+				Tenant:          w.tenant.id,
+				Path:            w.path,
+				IncrementBy:     w.incBy,
+				IntervalSeconds: w.incIntervalSecs,
+				StartedAt:       w.startedAt,
+				Ticks:           w.ticks.Load(),
+			})
+		}
+	})
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Tenant != out[j].Tenant {
+			return out[i].Tenant < out[j].Tenant
+		}
+		return out[i].Path < out[j].Path
+	})
+	return out
+}
+
+// apiRoutes are the handlers of the API route by mux pattern.
+func (a *App) apiRoutes() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"GET /api/state":             a.handleAPIState,
+		"POST /api/increment":        a.handleAPIIncrement,
+		"POST /api/workers":          a.handleAPIStartWorker,
+		"POST /api/workers/stop":     a.handleAPIStopWorker,
+		"POST /api/series/reset":     a.handleAPIResetSeries,
+		"POST /api/series/created":   a.handleAPISetSeriesCreated,
+		"POST /api/targets":          a.handleAPITargets,
+		"POST /api/collectors":       a.handleAPICollectors,
+		"POST /api/variants":         a.handleAPIDeclareVariant,
+		"POST /api/variants/remove":  a.handleAPIRemoveVariant,
+		"POST /api/timestamps":       a.handleAPITimestamps,
+		"POST /api/workload":         a.handleAPIWorkload,
+		"POST /api/downstream":       a.handleAPIDownstream,
+		"POST /api/replay":           a.handleAPIReplay,
+		"POST /api/control/replay":   a.handleAPIControlReplay,
+		"GET /api/control/recording": a.handleAPIControlRecording,
+		"GET /api/series":            a.handleAPISeries,
+		"GET /api/fleet/series":      a.handleAPIFleetSeries,
+		"GET /api/cluster":           a.handleAPICluster,
+		"POST /api/faults":           a.handleAPIFault,
+	}
+}
+
+func (a *App) newAPIHandler() http.Handler {
+	mux := http.NewServeMux()
+	for pattern, h := range a.apiRoutes() {
+		mux.HandleFunc(pattern, h)
+	}
+	return mux
+}
+
+func (a *App) handleAPIState(w http.ResponseWriter, r *http.Request) {
+	var cluster *ClusterView
+	if view, ok := a.Cluster(); ok {
+		cluster = &view
+	}
+	writeJSON(w, http.StatusOK, stateResponse{
+		Tenants:    append([]string{}, a.Tenants()...),
+		Targets:    a.TargetCount(),
+		Collectors: append([]string{}, a.CollectorIDs()...),
+		Metrics:    a.Metrics(),
+		Variants:   a.Variants(),
+		Timestamps: a.ScrapeTimestamps(),
+		Workload:   a.Workload(),
+		Downstream: a.Downstream(),
+		Series:     a.ledger.snapshot(),
+		Workers:    a.Workers(),
+		Scrapes:    a.scrapes.snapshot(),
+		Exports:    a.exports.snapshot(),
+		Replays:    a.replays.snapshot(),
+		Cluster:    cluster,
+	})
+}
+
+func (a *App) handleAPIIncrement(w http.ResponseWriter, r *http.Request) {
+	var req pathIncrementRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if !validPath(w, req.Path) {
+		return
+	}
+	req.Tenant = a.requestTenant(r, req.Tenant)
+	if err := a.Increment(req.Path, req.IncrementRequest); err != nil {
+		if !writeLimitError(w, err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, req)
+}
+
+func (a *App) handleAPIStartWorker(w http.ResponseWriter, r *http.Request) {
+	var req workerRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if !validPath(w, req.Path) {
+		return
+	}
+	if req.IncrementBy == 0 {
+		req.IncrementBy = defaultIncrementBy
+	}
+	if req.IntervalSeconds <= 0 {
+		req.IntervalSeconds = defaultIntervalSecs
+	}
+	req.Tenant = a.requestTenant(r, req.Tenant)
+	log.Printf("Starting interval worker for path %s: %d every %ds", req.Path, req.IncrementBy, req.IntervalSeconds)
+	if err := a.StartWorker(req.Tenant, req.Path, req.IncrementBy, req.IntervalSeconds); err != nil {
+		if !writeLimitError(w, err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, req)
+}
+
+func (a *App) handleAPIStopWorker(w http.ResponseWriter, r *http.Request) {
+	var req workerRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	req.Tenant = a.requestTenant(r, req.Tenant)
+	if !a.StopWorker(req.Tenant, req.Path) {
+		http.Error(w, fmt.Sprintf("No worker for path %q", req.Path), http.StatusNotFound)
+		return
+	}
+	log.Printf("Stopped interval worker for path %s", req.Path)
+	writeJSON(w, http.StatusOK, req)
+}
+
+func (a *App) handleAPIResetSeries(w http.ResponseWriter, r *http.Request) {
+	var req seriesRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	req.Tenant = a.requestTenant(r, req.Tenant)
+	series, ok := a.ResetSeries(req.Tenant, req.Path)
+	if !ok {
+		http.Error(w, fmt.Sprintf("No series for path %q", req.Path), http.StatusNotFound)
+		return
+	}
+	log.Printf("Reset series for path %s", req.Path)
+	writeJSON(w, http.StatusOK, series)
+}
+
+func (a *App) handleAPISetSeriesCreated(w http.ResponseWriter, r *http.Request) {
+	var req seriesRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.Created.IsZero() {
+		http.Error(w, "created must be set", http.StatusBadRequest)
+		return
+	}
+	req.Tenant = a.requestTenant(r, req.Tenant)
+	series, ok := a.SetSeriesCreated(req.Tenant, req.Path, req.Created)
+	if !ok {
+		http.Error(w, fmt.Sprintf("No series for path %q", req.Path), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, series)
+}
+
+func (a *App) handleAPITargets(w http.ResponseWriter, r *http.Request) {
+	var req targetsRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if err := a.SetTargetCount(req.Count); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, req)
+}
+
+func (a *App) handleAPICollectors(w http.ResponseWriter, r *http.Request) {
+	var req collectorsRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	a.SetCollectorIDs(req.CollectorIDs)
+	writeJSON(w, http.StatusOK, req)
+}
+
+func (a *App) handleAPIDeclareVariant(w http.ResponseWriter, r *http.Request) {
+	var req MetricVariant
+	if !readJSON(w, r, &req) {
+		return
+	}
+	v, err := a.DeclareVariant(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, v)
+}
+
+func (a *App) handleAPIRemoveVariant(w http.ResponseWriter, r *http.Request) {
+	var req removeVariantRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if !a.RemoveVariant(req.Registry, req.Name) {
+		http.Error(w, fmt.Sprintf("No variant %q in registry %q", req.Name, req.Registry), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, req)
+}
+
+func (a *App) handleAPITimestamps(w http.ResponseWriter, r *http.Request) {
+	var req ScrapeTimestampsConfig
+	if !readJSON(w, r, &req) {
+		return
+	}
+	cfg, err := a.SetScrapeTimestamps(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Scrape timestamps set to %s with offset %ds", cfg.Mode, cfg.OffsetSeconds)
+	writeJSON(w, http.StatusOK, cfg)
+}
+
+func (a *App) handleAPIWorkload(w http.ResponseWriter, r *http.Request) {
+	var req WorkloadConfig
+	if !readJSON(w, r, &req) {
+		return
+	}
+	cfg, err := a.SetWorkload(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Workload set to %d path profiles", len(cfg.Profiles))
+	writeJSON(w, http.StatusOK, cfg)
+}
+
+func (a *App) handleAPIDownstream(w http.ResponseWriter, r *http.Request) {
+	var req DownstreamConfig
+	if !readJSON(w, r, &req) {
+		return
+	}
+	cfg, err := a.SetDownstream(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	log.Printf("Downstream set to %d calls", len(cfg.Calls))
+	writeJSON(w, http.StatusOK, cfg)
+}
+
+// handleAPIReplay parses the recorded OTLP file in the body and replays it
+// in the background, so long recordings do not hold the request open.
+func (a *App) handleAPIReplay(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := ReplayOptions{
+		Cadence: q.Get("cadence"),
+		Rebase:  q.Get("rebase") == "true",
+		Tenant:  a.requestTenant(r, q.Get("tenant")),
+	}
+	var ok bool
+	if opts.Speed, ok = parseSpeed(w, q.Get("speed")); !ok {
+		return
+	}
+	for _, kv := range q["resource"] {
+		k, v, _ := strings.Cut(kv, "=")
+		if opts.Resource == nil {
+			opts.Resource = make(map[string]string)
+		}
+		opts.Resource[k] = v
+	}
+	if err := opts.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	format := q.Get("format")
+	if format == "" {
+		format = ReplayFormatProto
+		if ct := r.Header.Get("Content-Type"); strings.Contains(ct, "json") {
+			format = ReplayFormatJSON
+		}
+	}
+	reqs, err := readOTLPRequests(r.Body, format)
+	if err != nil {
+		if !writeBodyTooLargeError(w, err) {
+			http.Error(w, "Invalid OTLP file: "+err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	source := q.Get("source")
+	if source == "" {
+		source = "api"
+	}
+	log.Printf("Replaying %d OTLP requests from %s", len(reqs), source)
+	go a.replay(context.Background(), source, reqs, opts)
+	writeJSON(w, http.StatusAccepted, replayResponse{Source: source, Requests: len(reqs), ReplayOptions: opts})
+}
+
+func (a *App) handleAPIFault(w http.ResponseWriter, r *http.Request) {
+	var req faultRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	switch req.Kind {
+	case "restart":
+		a.handleForceRestart(w, r)
+	default:
+		http.Error(w, fmt.Sprintf("Unknown fault kind %q, expected one of %v", req.Kind, faultKinds), http.StatusBadRequest)
+	}
+}
+
+// parseSpeed parses a replay speed query parameter, which may be empty.
+func parseSpeed(w http.ResponseWriter, s string) (float64, bool) {
+	if s == "" {
+		return 0, true
+	}
+	speed, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		http.Error(w, "Invalid speed: "+err.Error(), http.StatusBadRequest)
+		return 0, false
+	}
+	return speed, true
+}
+
+func validPath(w http.ResponseWriter, path string) bool {
+	if err := validSeriesPath(path); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		if !writeBodyTooLargeError(w, err) {
+			http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		}
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
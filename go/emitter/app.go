@@ -0,0 +1,357 @@
+// Package emitter implements eriktestapp: an HTTP server that turns POSTed
+// increments into Prometheus and OTLP counters. Each App owns its own
+// registry, MeterProvider and workers, so several can run in one process.
+package emitter
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	defaultOTLPEndpoint   = "localhost:4317"
+	defaultInstanceID     = "erik-test-instance"
+	defaultExportInterval = 10 * time.Second
+)
+
+// App is one emitter instance.
+type App struct {
+	cfg            Config
+	otlpEndpoint   string
+	exporter       sdkmetric.Exporter
+	exportInterval time.Duration
+	instanceID     string
+	// processID is random per App, telling replicas with the same
+	// instanceID apart in fleet totals.
+	processID      string
+	registerer     prometheus.Registerer
+	gatherer       prometheus.Gatherer
+	openMetrics    bool
+	createdSamples bool
+	exit           func()
+
+	// defaultTenant serves requests that carry no tenant, from the
+	// registerer and gatherer above.
+	defaultTenant *tenant
+	tenantsMu     sync.RWMutex
+	tenants       map[string]*tenant
+
+	workers  shardedMap[*intervalWorker]
+	limits   *limiter
+	workload atomic.Pointer[WorkloadConfig]
+
+	spanExporter     sdktrace.SpanExporter
+	tracerProvider   *sdktrace.TracerProvider
+	downstream       atomic.Pointer[DownstreamConfig]
+	downstreamClient *http.Client
+	// logRequests logs every increment; off, the hot path does not log.
+	logRequests bool
+
+	targets   *targetSet
+	allocator *allocator
+	variants  variantSet
+	collector *scrapeCollector
+	// handlers are the routes listeners mount, built once by New.
+	handlers map[string]http.Handler
+	// controlHandlers are the control routes' handlers as replays call
+	// them, keyed by route name.
+	controlHandlers map[string]http.Handler
+
+	ledger  ledger
+	scrapes recentLog[ScrapeRecord]
+	exports recentLog[ExportRecord]
+	replays recentLog[ReplayRecord]
+	// cluster is nil unless cluster mode is on.
+	cluster *cluster
+	// baggageValues tracks the baggage values copied onto metrics.
+	baggageValues baggageValues
+
+	// controlLog is nil unless control requests are recorded.
+	controlLog *controlRecorder
+
+	// done is closed by Shutdown to stop background goroutines.
+	done chan struct{}
+	// shutdownOnce makes Shutdown safe to call more than once; shutdownErr
+	// is what the first call returned.
+	shutdownOnce sync.Once
+	shutdownErr  error
+}
+
+// Option configures an App.
+type Option func(*App)
+
+// WithConfig sets the app's configuration: listeners, tenants, limits and
+// every other section of Config. Without it the app serves
+// DefaultListeners with every other section at its defaults.
+func WithConfig(cfg Config) Option {
+	return func(a *App) {
+		a.cfg = cfg
+	}
+}
+
+// WithOTLPEndpoint sets the gRPC endpoint OTLP metrics are sent to.
+// Defaults to localhost:4317. Ignored when WithExporter is used.
+func WithOTLPEndpoint(endpoint string) Option {
+	return func(a *App) {
+		a.otlpEndpoint = endpoint
+	}
+}
+
+// WithExporter replaces the OTLP gRPC exporter, e.g. with an in-memory one
+// in tests. All tenants share it.
+func WithExporter(exp sdkmetric.Exporter) Option {
+	return func(a *App) {
+		a.exporter = exp
+	}
+}
+
+// WithSpanExporter sets the exporter spans are sent to instead of OTLP
+// over gRPC to the OTLP endpoint.
+func WithSpanExporter(exp sdktrace.SpanExporter) Option {
+	return func(a *App) {
+		a.spanExporter = exp
+	}
+}
+
+// WithExportInterval sets how often metrics are exported. Defaults to 10s.
+func WithExportInterval(d time.Duration) Option {
+	return func(a *App) {
+		a.exportInterval = d
+	}
+}
+
+// WithInstanceID sets the service.instance.id resource attribute.
+func WithInstanceID(id string) Option {
+	return func(a *App) {
+		a.instanceID = id
+	}
+}
+
+// WithPrometheus registers the app's Prometheus metrics with reg and serves
+// /metrics from g. Defaults to a fresh registry per App.
+func WithPrometheus(reg prometheus.Registerer, g prometheus.Gatherer) Option {
+	return func(a *App) {
+		a.registerer = reg
+		a.gatherer = g
+	}
+}
+
+// WithOpenMetrics sets the promhttp OpenMetrics negotiation options for
+// /metrics.
+func WithOpenMetrics(enabled, createdSamples bool) Option {
+	return func(a *App) {
+		a.openMetrics = enabled
+		a.createdSamples = createdSamples
+	}
+}
+
+// WithRequestLogging sets whether every increment is logged. Defaults to
+// true; turn it off to drive high request rates.
+func WithRequestLogging(enabled bool) Option {
+	return func(a *App) {
+		a.logRequests = enabled
+	}
+}
+
+// WithExitFunc sets what a restart fault does. Defaults to os.Exit(0).
+func WithExitFunc(fn func()) Option {
+	return func(a *App) {
+		a.exit = fn
+	}
+}
+
+// New builds an App and its MeterProvider. Nothing is served until Serve is
+// called.
+func New(ctx context.Context, opts ...Option) (*App, error) {
+	a := &App{
+		cfg:            Config{Listeners: DefaultListeners()},
+		otlpEndpoint:   defaultOTLPEndpoint,
+		exportInterval: defaultExportInterval,
+		instanceID:     defaultInstanceID,
+		processID:      rand.Text(),
+		exit:           func() { os.Exit(0) },
+		tenants:        make(map[string]*tenant),
+		logRequests:    true,
+		done:           make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.registerer == nil {
+		reg := prometheus.NewRegistry()
+		a.registerer, a.gatherer = reg, reg
+	}
+	if err := a.cfg.validate(); err != nil {
+		return nil, err
+	}
+	background, err := a.init(ctx)
+	if err != nil {
+		// Nothing runs in the background yet; release the providers built
+		// so far.
+		_ = a.Shutdown(ctx)
+		return nil, err
+	}
+	for _, run := range background {
+		go run()
+	}
+	return a, nil
+}
+
+// init does the setup New can fail at, returning the goroutines to start
+// once all of it has succeeded.
+func (a *App) init(ctx context.Context) ([]func(), error) {
+	a.limits = newLimiter(a.cfg.Limits)
+	a.ledger.maxSeries = maxSeries(a.cfg.Limits)
+	if _, err := a.SetWorkload(a.cfg.Workload); err != nil {
+		return nil, err
+	}
+
+	var err error
+	a.defaultTenant, err = a.newTenant(ctx, "", a.registerer, a.gatherer)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.initTracing(ctx); err != nil {
+		return nil, err
+	}
+	if _, err := a.SetDownstream(a.cfg.Downstream); err != nil {
+		return nil, err
+	}
+	a.downstreamClient = a.newDownstreamClient()
+	if a.exporter == nil {
+		log.Printf("OTLP metrics initialized, sending to endpoint: %s", a.otlpEndpoint)
+	}
+
+	a.collector = newScrapeCollector(a.cfg.ScrapeTimestamps)
+	if err := a.registerer.Register(a.collector); err != nil {
+		return nil, err
+	}
+
+	// Variants in the unnamed registry are served from /metrics.
+	a.gatherer = prometheus.Gatherers{a.gatherer, variantGatherer{&a.variants, ""}}
+	for _, v := range a.cfg.Variants {
+		if _, err := a.DeclareVariant(v); err != nil {
+			return nil, err
+		}
+	}
+
+	var background []func()
+	if a.cfg.Cluster != nil {
+		a.cluster = newCluster(*a.cfg.Cluster)
+		background = append(background, a.runCluster)
+	}
+	a.targets = newTargetSet(a.cfg.Targets)
+	a.allocator = newAllocator(a.cfg.TargetAllocator)
+	if a.cfg.Targets.Count > 0 {
+		if err := a.SetTargetCount(a.cfg.Targets.Count); err != nil {
+			return nil, err
+		}
+	}
+	background = append(background, a.runTargetGenerator)
+	if churn := a.cfg.Targets.Churn; churn != nil {
+		background = append(background, func() { a.runTargetChurn(*churn) })
+	}
+	if path := a.cfg.Control.RecordFile; path != "" {
+		if a.controlLog, err = openControlRecorder(path); err != nil {
+			return nil, err
+		}
+		log.Printf("Recording control requests to %s", path)
+	}
+	a.handlers = a.routes()
+	if a.cfg.Control.ReplayFile != "" {
+		run, err := a.loadControlReplay(a.cfg.Control)
+		if err != nil {
+			return nil, err
+		}
+		background = append(background, run)
+	}
+	if a.cfg.Replay != nil {
+		run, err := a.loadReplay(*a.cfg.Replay)
+		if err != nil {
+			return nil, err
+		}
+		background = append(background, run)
+	}
+	return background, nil
+}
+
+// MeterProvider returns the app's MeterProvider, e.g. to install it as the
+// global one.
+func (a *App) MeterProvider() *sdkmetric.MeterProvider {
+	return a.defaultTenant.meterProvider
+}
+
+// Gatherer returns the gatherer /metrics is served from.
+func (a *App) Gatherer() prometheus.Gatherer {
+	return a.gatherer
+}
+
+// Serve runs every configured listener until ctx is done or one of them
+// fails. Listeners are shut down before it returns.
+func (a *App) Serve(ctx context.Context) error {
+	return serveListeners(ctx, a.cfg.Listeners, a.handlers)
+}
+
+// Handler returns the mux for the configured listener called name, or nil
+// if there is none. Use it to serve the app from an httptest.Server.
+func (a *App) Handler(name string) http.Handler {
+	for _, lc := range a.cfg.Listeners {
+		if lc.Name == name {
+			return newMux(lc, a.handlers)
+		}
+	}
+	return nil
+}
+
+// Shutdown stops all workers and generators and flushes and shuts down every tenant's
+// MeterProvider. Later calls do nothing and return the first call's error.
+func (a *App) Shutdown(ctx context.Context) error {
+	a.shutdownOnce.Do(func() { a.shutdownErr = a.shutdown(ctx) })
+	return a.shutdownErr
+}
+
+func (a *App) shutdown(ctx context.Context) error {
+	close(a.done)
+	a.workers.all(func(m map[seriesKey]*intervalWorker) {
+		for key, w := range m {
+			close(w.done)
+			delete(m, key)
+			a.limits.stopWorker()
+		}
+	})
+	var errs []error
+	for _, t := range a.allTenants() {
+		errs = append(errs, t.shutdown(ctx))
+	}
+	// A failed New shuts down an app whose default tenant may not exist.
+	if a.defaultTenant != nil {
+		errs = append(errs, a.defaultTenant.shutdown(ctx))
+	}
+	errs = append(errs, a.shutdownTracing(ctx))
+	if a.controlLog != nil {
+		errs = append(errs, a.controlLog.close())
+	}
+	return errors.Join(errs...)
+}
+
+// Flush exports every tenant's metrics now. In manual-reader mode this is
+// the only way anything is exported.
+func (a *App) Flush(ctx context.Context) error {
+	var errs []error
+	for _, t := range append(a.allTenants(), a.defaultTenant) {
+		errs = append(errs, t.flush(ctx))
+	}
+	errs = append(errs, a.flushTraces(ctx))
+	return errors.Join(errs...)
+}
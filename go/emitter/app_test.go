@@ -0,0 +1,152 @@
+package emitter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// discardExporter drops every export.
+type discardExporter struct{}
+
+func (discardExporter) Temporality(k sdkmetric.InstrumentKind) metricdata.Temporality {
+	return sdkmetric.DefaultTemporalitySelector(k)
+}
+
+func (discardExporter) Aggregation(k sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(k)
+}
+
+func (discardExporter) Export(context.Context, *metricdata.ResourceMetrics) error { return nil }
+func (discardExporter) ForceFlush(context.Context) error                          { return nil }
+func (discardExporter) Shutdown(context.Context) error                            { return nil }
+
+// captureExporter keeps the last export.
+type captureExporter struct {
+	discardExporter
+	mu   sync.Mutex
+	last *metricdata.ResourceMetrics
+}
+
+func (e *captureExporter) Export(_ context.Context, rm *metricdata.ResourceMetrics) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.last = rm
+	return nil
+}
+
+// int64Sum returns the points of the int64 sum called name in the app's
+// scope from the last export.
+func (e *captureExporter) int64Sum(t testing.TB, name string) []metricdata.DataPoint[int64] {
+	t.Helper()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.last == nil {
+		t.Fatal("nothing exported")
+	}
+	for _, sm := range e.last.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if sm.Scope.Name == scopeName && m.Name == name {
+				return m.Data.(metricdata.Sum[int64]).DataPoints
+			}
+		}
+	}
+	t.Fatalf("no sum %s exported", name)
+	return nil
+}
+
+// testOptions keep an App off the network and quiet.
+func testOptions(cfg Config) []Option {
+	if cfg.Listeners == nil {
+		cfg.Listeners = DefaultListeners()
+	}
+	return []Option{
+		WithConfig(cfg),
+		WithExporter(discardExporter{}),
+		WithSpanExporter(tracetest.NewNoopExporter()),
+		WithRequestLogging(false),
+		WithExitFunc(func() {}),
+	}
+}
+
+// newTestApp builds an App that is shut down when the test ends.
+func newTestApp(t testing.TB, cfg Config, opts ...Option) *App {
+	t.Helper()
+	a, err := New(context.Background(), append(testOptions(cfg), opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = a.Shutdown(context.Background()) })
+	return a
+}
+
+// postIncrement sends body to path on h, returning the response.
+func postIncrement(h http.Handler, path, body string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestNewFailureStartsNothing(t *testing.T) {
+	before := runtime.NumGoroutine()
+	cfg := Config{
+		Targets: TargetsConfig{Count: 2, Churn: &ChurnConfig{IntervalSeconds: 1, Max: 4}},
+		Replay:  &ReplayConfig{File: filepath.Join(t.TempDir(), "missing.json")},
+	}
+	if _, err := New(context.Background(), testOptions(cfg)...); err == nil {
+		t.Fatal("New succeeded with a missing replay file")
+	}
+	// Goroutines stopped by Shutdown may take a moment to return.
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("%d goroutines before New, %d after:\n%s", before, runtime.NumGoroutine(), buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestShutdownTwice(t *testing.T) {
+	a := newTestApp(t, Config{})
+	if w := postIncrement(a.Handler("http"), "/a", `{"incrementBy":1,"incrementIntervalSeconds":60}`); w.Code != http.StatusOK {
+		t.Fatalf("POST /a: %d %s", w.Code, w.Body)
+	}
+	if err := a.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// The cleanup registered by newTestApp shuts the app down once more.
+	if err := a.Shutdown(context.Background()); err != nil {
+		t.Errorf("second Shutdown: %v", err)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	a := newTestApp(t, Config{})
+	if a.Handler("nope") != nil {
+		t.Error(`Handler("nope") is not nil`)
+	}
+	for range 2 {
+		if w := postIncrement(a.Handler("http"), "/a", `{"incrementBy":2}`); w.Code != http.StatusOK {
+			t.Fatalf("POST /a: %d %s", w.Code, w.Body)
+		}
+	}
+	if got := a.ledger.total(seriesKey{"", "/a"}); got != 4 {
+		t.Errorf("total = %d, want 4", got)
+	}
+}
@@ -0,0 +1,160 @@
+package emitter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// Signals baggage can be copied onto.
+const (
+	SignalMetrics = "metrics"
+	SignalSpans   = "spans"
+	SignalLogs    = "logs"
+)
+
+var baggageSignals = []string{SignalMetrics, SignalSpans, SignalLogs}
+
+const (
+	defaultMaxBaggageValues = 100
+	// baggageOverflowValue is what values of a key past its cap become on
+	// metrics.
+	baggageOverflowValue = "other"
+)
+
+// BaggageConfig copies allowlisted members of the W3C baggage of incoming
+// increments, as extracted by the otelhttp handler, onto attributes of the
+// same name. Each value becomes a new series of the OTLP counter and the
+// otelhttp server metrics, so values past MaxValues per key are folded
+// into "other" on metrics. The ledger stays keyed by tenant and path: the expected totals, and the
+// Prometheus counter on /metrics served from them, add up every baggage
+// value of a path, while resets and created timestamps apply to all of the
+// path's OTLP series.
+type BaggageConfig struct {
+	// Keys are the baggage members copied; without any, none are.
+	Keys []string `json:"keys,omitempty"`
+	// Signals are where they are copied to: metrics (the OTLP counter and
+	// the otelhttp server metrics), spans (the server span) and logs (the
+	// increment's log line). Defaults to all three.
+	Signals []string `json:"signals,omitempty"`
+	// MaxValues caps the distinct values of each key copied onto metrics;
+	// later values are copied as "other". Spans and logs get every value.
+	// Defaults to 100; -1 means no cap.
+	MaxValues int `json:"maxValues,omitempty"`
+}
+
+func (c BaggageConfig) validate() error {
+	if c.MaxValues < -1 {
+		return fmt.Errorf("baggage maxValues must be -1 or more, got %d", c.MaxValues)
+	}
+	for _, s := range c.Signals {
+		if !slices.Contains(baggageSignals, s) {
+			return fmt.Errorf("unknown baggage signal %q, expected one of %v", s, baggageSignals)
+		}
+	}
+	for _, k := range c.Keys {
+		if k == "" {
+			return fmt.Errorf("baggage keys must not be empty")
+		}
+	}
+	return nil
+}
+
+// copiesTo reports whether allowlisted baggage is copied onto signal.
+func (c BaggageConfig) copiesTo(signal string) bool {
+	return len(c.Keys) > 0 && (len(c.Signals) == 0 || slices.Contains(c.Signals, signal))
+}
+
+// maxValues returns the cap on each key's values, or 0 for none.
+func (c BaggageConfig) maxValues() int {
+	switch c.MaxValues {
+	case 0:
+		return defaultMaxBaggageValues
+	case -1:
+		return 0
+	}
+	return c.MaxValues
+}
+
+// baggageValues remembers the values each baggage key has been copied onto
+// metrics with, up to a cap.
+type baggageValues struct {
+	mu     sync.Mutex
+	values map[string]map[string]bool
+}
+
+// fold returns value if key has been seen with it or with fewer than limit
+// values, and baggageOverflowValue otherwise. A limit of 0 keeps every
+// value.
+func (v *baggageValues) fold(key, value string, limit int) string {
+	if limit == 0 {
+		return value
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	seen := v.values[key]
+	if seen[value] {
+		return value
+	}
+	if len(seen) >= limit {
+		return baggageOverflowValue
+	}
+	if seen == nil {
+		if v.values == nil {
+			v.values = make(map[string]map[string]bool)
+		}
+		seen = make(map[string]bool)
+		v.values[key] = seen
+	}
+	seen[value] = true
+	return value
+}
+
+// baggageAttributes returns the allowlisted baggage of ctx as attributes,
+// in allowlist order, if it is copied onto signal. Values for metrics are
+// folded past the per-key cap.
+func (a *App) baggageAttributes(ctx context.Context, signal string) []attribute.KeyValue {
+	cfg := a.cfg.Baggage
+	if !cfg.copiesTo(signal) {
+		return nil
+	}
+	bag := baggage.FromContext(ctx)
+	if bag.Len() == 0 {
+		return nil
+	}
+	var attrs []attribute.KeyValue
+	for _, k := range cfg.Keys {
+		if m := bag.Member(k); m.Key() != "" {
+			value := m.Value()
+			if signal == SignalMetrics {
+				value = a.baggageValues.fold(k, value, cfg.maxValues())
+			}
+			attrs = append(attrs, attribute.String(k, value))
+		}
+	}
+	return attrs
+}
+
+// serverMetricAttributes adds the allowlisted baggage to the otelhttp
+// server metrics of the increment route.
+func (a *App) serverMetricAttributes(r *http.Request) []attribute.KeyValue {
+	return a.baggageAttributes(r.Context(), SignalMetrics)
+}
+
+// formatAttributes renders attrs for a log line, e.g. " [team=a flag=b]".
+func formatAttributes(attrs []attribute.KeyValue) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+	parts := make([]string, len(attrs))
+	for i, kv := range attrs {
+		parts[i] = string(kv.Key) + "=" + kv.Value.Emit()
+	}
+	return " [" + strings.Join(parts, " ") + "]"
+}
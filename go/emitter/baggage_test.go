@@ -0,0 +1,47 @@
+package emitter
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"testing"
+)
+
+// TestBaggageMaxValues checks values past a key's cap are folded into
+// "other" on the OTLP counter, keeping its series bounded.
+func TestBaggageMaxValues(t *testing.T) {
+	exp := &captureExporter{}
+	a := newTestApp(t, Config{
+		SDK:     SDKConfig{Reader: ReaderManual},
+		Baggage: BaggageConfig{Keys: []string{"customer"}, MaxValues: 2},
+	}, WithExporter(exp))
+	h := a.Handler("http")
+	for _, customer := range []string{"x", "y", "z", "x", "w"} {
+		if w := postIncrement(h, "/a", `{"incrementBy":1}`, "baggage", "customer="+customer); w.Code != http.StatusOK {
+			t.Fatalf("POST /a: %d %s", w.Code, w.Body)
+		}
+	}
+	if err := a.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]int64)
+	for _, dp := range exp.int64Sum(t, otlpSumCounterName) {
+		customer, _ := dp.Attributes.Value("customer")
+		got[customer.AsString()] = dp.Value
+	}
+	if want := map[string]int64{"x": 2, "y": 1, baggageOverflowValue: 2}; !maps.Equal(got, want) {
+		t.Errorf("values by customer = %v, want %v", got, want)
+	}
+	if got := a.ledger.total(seriesKey{"", "/a"}); got != 5 {
+		t.Errorf("total = %d, want 5", got)
+	}
+}
+
+func TestBaggageConfigValidate(t *testing.T) {
+	if err := (BaggageConfig{MaxValues: -2}).validate(); err == nil {
+		t.Error("maxValues -2 is valid")
+	}
+	if err := (BaggageConfig{MaxValues: -1}).validate(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,491 @@
+package emitter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultClusterService        = "erikwutest-headless"
+	defaultClusterRefreshSeconds = 10
+	// forwardedHeader marks a request forwarded to its owner, which then
+	// serves it even if its own view disagrees, so requests never bounce.
+	// It is only trusted from members; see forwardedByPeer.
+	forwardedHeader = "X-Erik-Forwarded-By"
+)
+
+// peerClient makes the requests replicas send each other.
+var peerClient = &http.Client{Timeout: 5 * time.Second}
+
+// ClusterConfig turns on cluster mode: replicas find each other through
+// the A records of a headless service and split the workload. The virtual
+// targets are dealt round-robin across replicas, and every series path is
+// owned by one replica, to which increments, workers and series changes
+// for it are forwarded. The replica with the lowest address coordinates:
+// the others adopt its member list and target count, so all agree on who
+// owns what, and target changes and churn go through it.
+type ClusterConfig struct {
+	// Service is the DNS name to resolve. Defaults to erikwutest-headless.
+	Service string `json:"service,omitempty"`
+	// Self is this replica's address as DNS lists it. Defaults to POD_IP,
+	// then to the first interface address found in DNS.
+	Self string `json:"self,omitempty"`
+	// RefreshSeconds defaults to 10.
+	RefreshSeconds int `json:"refreshSeconds,omitempty"`
+}
+
+func (c ClusterConfig) validate() error {
+	if c.RefreshSeconds < 0 {
+		return fmt.Errorf("cluster refresh must not be negative, got %d", c.RefreshSeconds)
+	}
+	if c.Self != "" && net.ParseIP(c.Self) == nil {
+		return fmt.Errorf("cluster self address %q is not an IP", c.Self)
+	}
+	return nil
+}
+
+// ClusterView is one replica's picture of the cluster.
+type ClusterView struct {
+	Self        string   `json:"self"`
+	Coordinator string   `json:"coordinator"`
+	Members     []string `json:"members"`
+	// Targets is the number of virtual targets across the cluster.
+	Targets int       `json:"targets"`
+	Updated time.Time `json:"updated"`
+	// Error is why the last refresh failed, if it did.
+	Error string `json:"error,omitempty"`
+}
+
+// Coordinating reports whether the view's own replica is the coordinator.
+func (v ClusterView) Coordinating() bool {
+	return v.Self != "" && v.Self == v.Coordinator
+}
+
+type cluster struct {
+	service string
+	refresh time.Duration
+
+	mu   sync.RWMutex
+	view ClusterView
+}
+
+func newCluster(cfg ClusterConfig) *cluster {
+	c := &cluster{
+		service: cfg.Service,
+		refresh: time.Duration(cfg.RefreshSeconds) * time.Second,
+		view:    ClusterView{Self: cfg.Self},
+	}
+	if c.service == "" {
+		c.service = defaultClusterService
+	}
+	if c.refresh <= 0 {
+		c.refresh = defaultClusterRefreshSeconds * time.Second
+	}
+	if c.view.Self == "" {
+		c.view.Self = os.Getenv("POD_IP")
+	}
+	return c
+}
+
+func (c *cluster) snapshot() ClusterView {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	v := c.view
+	v.Members = slices.Clone(v.Members)
+	return v
+}
+
+// isMember reports whether addr is in the current member list.
+func (c *cluster) isMember(addr string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return slices.Contains(c.view.Members, addr)
+}
+
+// ownerOfTarget returns the member serving virtual target i.
+func (c *cluster) ownerOfTarget(i int) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.view.Members) == 0 {
+		return c.view.Self
+	}
+	return c.view.Members[i%len(c.view.Members)]
+}
+
+// ownerOfSeries returns the member owning key by rendezvous hashing, so a
+// membership change only moves the series of the members that changed.
+func (c *cluster) ownerOfSeries(key seriesKey) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.view.Members) == 0 {
+		return c.view.Self
+	}
+	k := fnv.New64a()
+	fmt.Fprintf(k, "%s\x00%s", key.tenant, key.path)
+	var owner string
+	var best uint64
+	for i, m := range c.view.Members {
+		h := fnv.New64a()
+		h.Write([]byte(m))
+		if score := mix64(k.Sum64() ^ h.Sum64()); i == 0 || score > best {
+			owner, best = m, score
+		}
+	}
+	return owner
+}
+
+// mix64 is the splitmix64 finalizer. FNV alone barely separates members
+// whose addresses differ in one byte.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// lookup resolves the service and works out the own address if unset.
+func (c *cluster) lookup(ctx context.Context) ([]string, string, error) {
+	addrs, err := net.DefaultResolver.LookupHost(ctx, c.service)
+	if err != nil {
+		return nil, "", err
+	}
+	slices.Sort(addrs)
+	self := c.snapshot().Self
+	if self == "" {
+		self = localAddressIn(addrs)
+	}
+	return addrs, self, nil
+}
+
+// localAddressIn returns the first address of a local interface found in
+// addrs, or "".
+func localAddressIn(addrs []string) string {
+	ifaddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ""
+	}
+	for _, ia := range ifaddrs {
+		if ipnet, ok := ia.(*net.IPNet); ok && slices.Contains(addrs, ipnet.IP.String()) {
+			return ipnet.IP.String()
+		}
+	}
+	return ""
+}
+
+// refreshCluster resolves the members and, unless this replica is the
+// coordinator, adopts the coordinator's member list. It reports whether
+// the members changed.
+func (a *App) refreshCluster(ctx context.Context) bool {
+	c := a.cluster
+	members, self, err := c.lookup(ctx)
+	view := ClusterView{Self: self, Updated: time.Now()}
+	if err != nil {
+		view.Error = err.Error()
+		// Keep the last known members rather than claim everything.
+		old := c.snapshot()
+		view.Members, view.Coordinator = old.Members, old.Coordinator
+	} else {
+		view.Members = members
+		if len(members) > 0 {
+			view.Coordinator = members[0]
+		}
+		if !view.Coordinating() && view.Coordinator != "" {
+			if coord, err := a.fetchClusterView(ctx, view.Coordinator); err != nil {
+				view.Error = fmt.Sprintf("asking coordinator %s: %v", view.Coordinator, err)
+			} else {
+				if len(coord.Members) > 0 {
+					view.Members = coord.Members
+				}
+				if err := a.SetTargetCount(coord.Targets); err != nil {
+					view.Error = fmt.Sprintf("following coordinator %s: %v", view.Coordinator, err)
+				}
+			}
+		}
+	}
+
+	c.mu.Lock()
+	changed := !slices.Equal(c.view.Members, view.Members) || c.view.Self != view.Self
+	c.view = view
+	c.mu.Unlock()
+	if changed {
+		log.Printf("Cluster members are now %v; self %s, coordinator %s", view.Members, view.Self, view.Coordinator)
+	}
+	return changed
+}
+
+func (a *App) fetchClusterView(ctx context.Context, peer string) (ClusterView, error) {
+	var view ClusterView
+	u, ok := a.peerURL(peer, RouteAPI, "/api/cluster")
+	if !ok {
+		return view, fmt.Errorf("no TCP listener serves the %s route", RouteAPI)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return view, err
+	}
+	resp, err := peerClient.Do(req)
+	if err != nil {
+		return view, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return view, fmt.Errorf("status %s", resp.Status)
+	}
+	return view, json.NewDecoder(resp.Body).Decode(&view)
+}
+
+// runCluster refreshes the members until done is closed, handing off
+// workers whose series moved to another replica.
+func (a *App) runCluster() {
+	ticker := time.NewTicker(a.cluster.refresh)
+	defer ticker.Stop()
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), a.cluster.refresh)
+		if a.refreshCluster(ctx) {
+			a.handOffWorkers(ctx)
+		}
+		cancel()
+		select {
+		case <-ticker.C:
+		case <-a.done:
+			return
+		}
+	}
+}
+
+// handOffWorkers restarts every local worker whose series another replica
+// now owns on that replica. A worker whose hand-off fails keeps running
+// here. Totals already counted stay in this replica's ledger.
+func (a *App) handOffWorkers(ctx context.Context) {
+	self := a.cluster.snapshot().Self
+	for _, w := range a.Workers() {
+		owner := a.cluster.ownerOfSeries(seriesKey{w.Tenant, w.Path})
+		if owner == self || owner == "" {
+			continue
+		}
+		body, _ := json.Marshal(workerRequest{Tenant: w.Tenant, Path: w.Path, IncrementBy: w.IncrementBy, IntervalSeconds: w.IntervalSeconds})
+		if err := a.postToPeer(ctx, owner, RouteAPI, "/api/workers", body); err != nil {
+			log.Printf("Handing worker for path %s to %s: %v", w.Path, owner, err)
+			continue
+		}
+		a.StopWorker(w.Tenant, w.Path)
+		log.Printf("Handed worker for path %s to %s", w.Path, owner)
+	}
+}
+
+func (a *App) postToPeer(ctx context.Context, peer, route, path string, body []byte) error {
+	u, ok := a.peerURL(peer, route, path)
+	if !ok {
+		return fmt.Errorf("no TCP listener serves the %s route", route)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(forwardedHeader, a.cluster.snapshot().Self)
+	resp, err := peerClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// peerHost returns peer's host:port for the listener serving route, whose
+// port every replica shares.
+func (a *App) peerHost(peer, route string) (string, bool) {
+	for _, lc := range a.cfg.Listeners {
+		if !slices.Contains(lc.Routes, route) {
+			continue
+		}
+		_, port, err := net.SplitHostPort(lc.Addr)
+		if err != nil {
+			continue
+		}
+		return net.JoinHostPort(peer, port), true
+	}
+	return "", false
+}
+
+func (a *App) peerURL(peer, route, path string) (string, bool) {
+	host, ok := a.peerHost(peer, route)
+	return "http://" + host + path, ok
+}
+
+// requestOwner returns the replica that must serve a control request:
+// the coordinator for target changes and the series' owner for requests
+// acting on a series. The body is restored for the handler; failing to
+// read it is the only error.
+func (a *App) requestOwner(route string, r *http.Request) (string, bool, error) {
+	if r.Method != http.MethodPost {
+		return "", false, nil
+	}
+	switch {
+	case route == RouteAPI && r.URL.Path == "/api/targets":
+		return a.cluster.snapshot().Coordinator, true, nil
+	case route == RouteIncrement:
+	case route == RouteAPI && seriesAPIPaths[r.URL.Path]:
+	default:
+		return "", false, nil
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return "", false, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	var req struct {
+		Tenant string `json:"tenant"`
+		Path   string `json:"path"`
+	}
+	if json.Unmarshal(body, &req) != nil {
+		return "", false, nil
+	}
+	if route == RouteIncrement {
+		req.Path = r.URL.Path
+	}
+	return a.cluster.ownerOfSeries(seriesKey{a.requestTenant(r, req.Tenant), req.Path}), true, nil
+}
+
+// checkOwner fails with FailedPrecondition when another replica owns key.
+// gRPC calls are not forwarded, so clients must call the owner. Replayed
+// calls are served wherever they are replayed, as HTTP replays are.
+func (a *App) checkOwner(ctx context.Context, key seriesKey) error {
+	if a.cluster == nil || ctx.Value(replayingKey{}) != nil {
+		return nil
+	}
+	if owner := a.cluster.ownerOfSeries(key); owner != "" && owner != a.cluster.snapshot().Self {
+		return status.Errorf(codes.FailedPrecondition, "series %s of tenant %q is owned by %s", key.path, key.tenant, owner)
+	}
+	return nil
+}
+
+// seriesAPIPaths are the API endpoints acting on one series.
+var seriesAPIPaths = map[string]bool{
+	"/api/increment":      true,
+	"/api/workers":        true,
+	"/api/workers/stop":   true,
+	"/api/series/reset":   true,
+	"/api/series/created": true,
+}
+
+// forwardToOwner wraps a route's handler so requests another replica must
+// serve are proxied to it. gRPC calls are not proxied; checkOwner rejects
+// those another replica must serve.
+func (a *App) forwardToOwner(route string, next http.Handler) http.Handler {
+	if a.cluster == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.forwardedByPeer(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		owner, ok, err := a.requestOwner(route, r)
+		if err != nil {
+			if !writeBodyTooLargeError(w, err) {
+				http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			}
+			return
+		}
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		self := a.cluster.snapshot().Self
+		host, ok := a.peerHost(owner, route)
+		if owner == self || owner == "" || !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		proxy := &httputil.ReverseProxy{
+			Rewrite: func(pr *httputil.ProxyRequest) {
+				pr.SetURL(&url.URL{Scheme: "http", Host: host})
+				pr.SetXForwarded()
+				pr.Out.Header.Set(forwardedHeader, self)
+			},
+		}
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+// forwardedByPeer reports whether r was forwarded by another replica: it
+// has the forwarded header and comes from a current member. Anyone can set
+// the header, so it is not trusted outside cluster mode.
+func (a *App) forwardedByPeer(r *http.Request) bool {
+	if a.cluster == nil || r.Header.Get(forwardedHeader) == "" {
+		return false
+	}
+	return a.cluster.isMember(clientAddress(r))
+}
+
+// Cluster returns this replica's view of the cluster, or false when
+// cluster mode is off.
+func (a *App) Cluster() (ClusterView, bool) {
+	if a.cluster == nil {
+		return ClusterView{}, false
+	}
+	view := a.cluster.snapshot()
+	view.Targets = a.TargetCount()
+	return view, true
+}
+
+// ownsTarget reports whether this replica serves and increments virtual
+// target i, returning the replica that does if not.
+func (a *App) ownsTarget(i int) (string, bool) {
+	if a.cluster == nil {
+		return "", true
+	}
+	owner := a.cluster.ownerOfTarget(i)
+	return owner, owner == "" || owner == a.cluster.snapshot().Self
+}
+
+// coordinating reports whether this replica decides cluster-wide settings,
+// which it always does outside cluster mode.
+func (a *App) coordinating() bool {
+	return a.cluster == nil || a.cluster.snapshot().Coordinating()
+}
+
+func (a *App) handleAPICluster(w http.ResponseWriter, r *http.Request) {
+	view, ok := a.Cluster()
+	if !ok {
+		http.Error(w, "Cluster mode is off", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, view)
+}
+
+// targetAddress is where service discovery sends scrapes of target i: its
+// owner's targets listener in cluster mode, fallback otherwise.
+func (a *App) targetAddress(i int, fallback string) string {
+	if a.cluster == nil {
+		return fallback
+	}
+	owner, _ := a.ownsTarget(i)
+	if host, ok := a.peerHost(owner, RouteTargets); ok && owner != "" {
+		return host
+	}
+	return fallback
+}
@@ -0,0 +1,140 @@
+package emitter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"eriktestapp/controlpb"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func testCluster(members ...string) *cluster {
+	c := newCluster(ClusterConfig{Self: "10.0.0.1"})
+	c.view.Members = members
+	return c
+}
+
+func TestOwnerOfSeries(t *testing.T) {
+	members := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"}
+	c := testCluster(members...)
+	const series = 4000
+	owners := make(map[seriesKey]string, series)
+	counts := make(map[string]int)
+	for i := range series {
+		key := seriesKey{"", "/" + strconv.Itoa(i)}
+		owner := c.ownerOfSeries(key)
+		if !slices.Contains(members, owner) {
+			t.Fatalf("%v owned by %q, not a member", key, owner)
+		}
+		if again := c.ownerOfSeries(key); again != owner {
+			t.Fatalf("%v owned by %q, then %q", key, owner, again)
+		}
+		owners[key] = owner
+		counts[owner]++
+	}
+	for _, m := range members {
+		if n := counts[m]; n < series/len(members)*3/4 {
+			t.Errorf("%s owns %d of %d series", m, n, series)
+		}
+	}
+
+	// Dropping a member only moves its own series.
+	c = testCluster(slices.Delete(slices.Clone(members), 1, 2)...)
+	for key, was := range owners {
+		if now := c.ownerOfSeries(key); was != members[1] && now != was {
+			t.Errorf("%v moved from %s to %s", key, was, now)
+		}
+	}
+}
+
+func TestOwnerOfSeriesTenant(t *testing.T) {
+	c := testCluster("10.0.0.1", "10.0.0.2", "10.0.0.3")
+	var differ bool
+	for i := 0; i < 100 && !differ; i++ {
+		path := "/" + strconv.Itoa(i)
+		differ = c.ownerOfSeries(seriesKey{"a", path}) != c.ownerOfSeries(seriesKey{"b", path})
+	}
+	if !differ {
+		t.Error("tenants do not affect ownership")
+	}
+}
+
+func TestOwnerWithoutMembers(t *testing.T) {
+	c := testCluster()
+	if got := c.ownerOfSeries(seriesKey{"", "/a"}); got != "10.0.0.1" {
+		t.Errorf("ownerOfSeries = %q, want self", got)
+	}
+	if got := c.ownerOfTarget(3); got != "10.0.0.1" {
+		t.Errorf("ownerOfTarget = %q, want self", got)
+	}
+}
+
+func TestOwnerOfTarget(t *testing.T) {
+	c := testCluster("10.0.0.1", "10.0.0.2", "10.0.0.3")
+	for i, want := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.1"} {
+		if got := c.ownerOfTarget(i); got != want {
+			t.Errorf("ownerOfTarget(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
+
+// pathOwnedBy returns a default-tenant path c places on owner.
+func pathOwnedBy(t *testing.T, c *cluster, owner string) string {
+	t.Helper()
+	for i := range 1000 {
+		if path := "/" + strconv.Itoa(i); c.ownerOfSeries(seriesKey{"", path}) == owner {
+			return path
+		}
+	}
+	t.Fatalf("no path owned by %s", owner)
+	return ""
+}
+
+// TestForwardBodyTooLarge checks a chunked body over the cap is refused
+// with 413 by the forwarding that reads it, not treated as unroutable.
+func TestForwardBodyTooLarge(t *testing.T) {
+	a := newTestApp(t, Config{Limits: LimitsConfig{MaxBodyBytes: 16}})
+	a.cluster = testCluster("10.0.0.1", "10.0.0.2")
+	h := a.limitIncrements(a.forwardToOwner(RouteIncrement, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Error("oversized request reached the handler")
+	})))
+	req := httptest.NewRequest(http.MethodPost, "/a", io.MultiReader(strings.NewReader(`{"incrementBy":1`), strings.NewReader(strings.Repeat(" ", 64)+"}")))
+	req.ContentLength = -1
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status %d, want 413: %s", w.Code, w.Body)
+	}
+}
+
+func TestGRPCOwner(t *testing.T) {
+	ctx := context.Background()
+	a := newTestApp(t, Config{})
+	a.cluster = testCluster("10.0.0.1", "10.0.0.2")
+	client := grpcClient(t, a)
+	local, remote := pathOwnedBy(t, a.cluster, "10.0.0.1"), pathOwnedBy(t, a.cluster, "10.0.0.2")
+
+	if _, err := client.Increment(ctx, &controlpb.IncrementRequest{Path: local, IncrementBy: 1}); err != nil {
+		t.Errorf("Increment of an owned series: %v", err)
+	}
+	if _, err := client.StartWorker(ctx, &controlpb.StartWorkerRequest{Path: local, IntervalSeconds: 3600}); err != nil {
+		t.Errorf("StartWorker of an owned series: %v", err)
+	}
+	if _, err := client.Increment(ctx, &controlpb.IncrementRequest{Path: remote, IncrementBy: 1}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("Increment of another replica's series: %v, want FailedPrecondition", err)
+	}
+	if _, err := client.StartWorker(ctx, &controlpb.StartWorkerRequest{Path: remote}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("StartWorker of another replica's series: %v, want FailedPrecondition", err)
+	}
+	if _, ok := a.ledger.state(seriesKey{"", remote}); ok {
+		t.Errorf("series %s owned by another replica was created", remote)
+	}
+}
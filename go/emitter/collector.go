@@ -0,0 +1,136 @@
+package emitter
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Timestamp modes for the scrape-time collector.
+const (
+	TimestampsOff    = "off"
+	TimestampsNone   = "none"
+	TimestampsNow    = "now"
+	TimestampsLagged = "lagged"
+	TimestampsFuture = "future"
+)
+
+const (
+	collectsCounterName    = "erik_scrape_collects_total"
+	sampleTimestampName    = "erik_scrape_sample_timestamp_seconds"
+	defaultTimestampOffset = 60
+)
+
+var timestampModes = []string{TimestampsOff, TimestampsNone, TimestampsNow, TimestampsLagged, TimestampsFuture}
+
+// ScrapeTimestampsConfig sets the mode of the collector that computes its
+// samples when /metrics is scraped. Off emits nothing, none emits the
+// samples without timestamps, and now, lagged and future attach an explicit
+// timestamp of now, now minus OffsetSeconds or now plus OffsetSeconds.
+type ScrapeTimestampsConfig struct {
+	// Mode defaults to off; change it with POST /api/timestamps.
+	Mode string `json:"mode,omitempty"`
+	// OffsetSeconds defaults to 60.
+	OffsetSeconds int `json:"offsetSeconds,omitempty"`
+}
+
+func (c ScrapeTimestampsConfig) validate() error {
+	if c.Mode != "" && !slices.Contains(timestampModes, c.Mode) {
+		return fmt.Errorf("unknown timestamp mode %q, expected one of %v", c.Mode, timestampModes)
+	}
+	if c.OffsetSeconds < 0 {
+		return fmt.Errorf("timestamp offset must not be negative, got %d", c.OffsetSeconds)
+	}
+	return nil
+}
+
+func (c ScrapeTimestampsConfig) withDefaults() ScrapeTimestampsConfig {
+	if c.Mode == "" {
+		c.Mode = TimestampsOff
+	}
+	if c.OffsetSeconds == 0 {
+		c.OffsetSeconds = defaultTimestampOffset
+	}
+	return c
+}
+
+// scrapeCollector computes its values in Collect rather than when something
+// is incremented, so every scrape sees fresh samples.
+type scrapeCollector struct {
+	collectsDesc  *prometheus.Desc
+	timestampDesc *prometheus.Desc
+	collects      atomic.Int64
+	created       time.Time
+
+	mu  sync.Mutex
+	cfg ScrapeTimestampsConfig
+}
+
+func newScrapeCollector(cfg ScrapeTimestampsConfig) *scrapeCollector {
+	return &scrapeCollector{
+		collectsDesc: prometheus.NewDesc(collectsCounterName,
+			"Number of times the scrape-time collector has been collected", nil, nil),
+		timestampDesc: prometheus.NewDesc(sampleTimestampName,
+			"Timestamp attached to this scrape's samples, or the collection time if none is attached", nil, nil),
+		created: time.Now(),
+		cfg:     cfg.withDefaults(),
+	}
+}
+
+func (c *scrapeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.collectsDesc
+	ch <- c.timestampDesc
+}
+
+func (c *scrapeCollector) Collect(ch chan<- prometheus.Metric) {
+	cfg := c.config()
+	if cfg.Mode == TimestampsOff {
+		return
+	}
+	ts := time.Now()
+	offset := time.Duration(cfg.OffsetSeconds) * time.Second
+	switch cfg.Mode {
+	case TimestampsLagged:
+		ts = ts.Add(-offset)
+	case TimestampsFuture:
+		ts = ts.Add(offset)
+	}
+	metrics := []prometheus.Metric{
+		prometheus.MustNewConstMetricWithCreatedTimestamp(c.collectsDesc, prometheus.CounterValue, float64(c.collects.Add(1)), c.created),
+		prometheus.MustNewConstMetric(c.timestampDesc, prometheus.GaugeValue, float64(ts.UnixMilli())/1000),
+	}
+	for _, m := range metrics {
+		if cfg.Mode != TimestampsNone {
+			m = prometheus.NewMetricWithTimestamp(ts, m)
+		}
+		ch <- m
+	}
+}
+
+func (c *scrapeCollector) config() ScrapeTimestampsConfig {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cfg
+}
+
+// SetScrapeTimestamps changes the scrape-time collector's mode and returns
+// the settings now in effect.
+func (a *App) SetScrapeTimestamps(cfg ScrapeTimestampsConfig) (ScrapeTimestampsConfig, error) {
+	if err := cfg.validate(); err != nil {
+		return cfg, err
+	}
+	cfg = cfg.withDefaults()
+	a.collector.mu.Lock()
+	defer a.collector.mu.Unlock()
+	a.collector.cfg = cfg
+	return cfg, nil
+}
+
+// ScrapeTimestamps returns the scrape-time collector's settings.
+func (a *App) ScrapeTimestamps() ScrapeTimestampsConfig {
+	return a.collector.config()
+}
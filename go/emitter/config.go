@@ -0,0 +1,299 @@
+package emitter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Config is the optional JSON document read from the file named by
+// CONFIG_FILE. Anything it leaves unset falls back to the environment.
+type Config struct {
+	Listeners []ListenerConfig `json:"listeners,omitempty"`
+	Tenants   TenantsConfig    `json:"tenants,omitempty"`
+	Targets   TargetsConfig    `json:"targets,omitempty"`
+	// TargetAllocator configures the emulated target allocator API.
+	TargetAllocator TargetAllocatorConfig `json:"targetAllocator,omitempty"`
+	// Variants are metric variants declared at startup.
+	Variants []MetricVariant `json:"variants,omitempty"`
+	// ScrapeTimestamps sets the scrape-time collector on /metrics.
+	ScrapeTimestamps ScrapeTimestampsConfig `json:"scrapeTimestamps,omitempty"`
+	// SDK declares views and limits for the MeterProviders.
+	SDK SDKConfig `json:"sdk,omitempty"`
+	// Replay sends a recorded OTLP file to the OTLP endpoint at startup.
+	Replay *ReplayConfig `json:"replay,omitempty"`
+	// Control records control requests and replays such recordings.
+	Control ControlConfig `json:"control,omitempty"`
+	// Cluster, if set, splits the workload across replicas.
+	Cluster *ClusterConfig `json:"cluster,omitempty"`
+	// Fleet names the replicas whose expected totals are merged.
+	Fleet FleetConfig `json:"fleet,omitempty"`
+	// Limits caps request rates, body sizes, workers and series.
+	Limits LimitsConfig `json:"limits,omitempty"`
+	// Workload simulates latency, errors and response sizes per path.
+	Workload WorkloadConfig `json:"workload,omitzero"`
+	// Tracing controls the app's spans.
+	Tracing TracingConfig `json:"tracing,omitzero"`
+	// Downstream makes increments call other services.
+	Downstream DownstreamConfig `json:"downstream,omitzero"`
+	// Baggage copies allowlisted baggage onto increments' telemetry.
+	Baggage BaggageConfig `json:"baggage,omitzero"`
+	// Semconv selects the semantic conventions version telemetry follows.
+	Semconv SemconvConfig `json:"semconv,omitzero"`
+}
+
+// TargetsConfig sets up virtual scrape targets, each with its own registry
+// served at /targets/{n}/metrics. A generator adds IncrementBy to each of
+// a target's SeriesPerTarget series every IntervalSeconds.
+type TargetsConfig struct {
+	// Count is the initial number of targets; change it with POST
+	// /api/targets. In cluster mode it counts the targets of all replicas.
+	Count int `json:"count,omitempty"`
+	// SeriesPerTarget defaults to 10.
+	SeriesPerTarget int `json:"seriesPerTarget,omitempty"`
+	// IncrementBy defaults to 1.
+	IncrementBy int `json:"incrementBy,omitempty"`
+	// IntervalSeconds defaults to 10.
+	IntervalSeconds int `json:"intervalSeconds,omitempty"`
+	// Address is the host:port service discovery hands out for every
+	// target. Defaults to the Host the discovery request was sent to.
+	Address string `json:"address,omitempty"`
+	// Labels are added to every target in service discovery.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Churn, if set, keeps changing the target count.
+	Churn *ChurnConfig `json:"churn,omitempty"`
+}
+
+// TargetAllocatorConfig shapes the emulated OpenTelemetry Operator target
+// allocator API (/jobs, /jobs/{job}/targets, /scrape_configs), which serves
+// the virtual targets split round-robin across CollectorIDs.
+type TargetAllocatorConfig struct {
+	// JobName defaults to "virtual-targets".
+	JobName string `json:"jobName,omitempty"`
+	// CollectorIDs defaults to ["collector-0"]; change it with POST
+	// /api/collectors.
+	CollectorIDs []string `json:"collectorIds,omitempty"`
+	// ScrapeInterval defaults to "10s".
+	ScrapeInterval string `json:"scrapeInterval,omitempty"`
+}
+
+// ChurnConfig makes the number of virtual targets random-walk between Min
+// and Max, by up to Step every IntervalSeconds.
+type ChurnConfig struct {
+	IntervalSeconds int `json:"intervalSeconds"`
+	Min             int `json:"min"`
+	Max             int `json:"max"`
+	// Step defaults to 1.
+	Step int `json:"step,omitempty"`
+}
+
+// TenantsConfig controls multi-tenant emission. A request's tenant comes
+// from the "tenant" payload field or, failing that, from Header. Each tenant
+// gets its own registry, served at /metrics/{tenant}, and its own OTLP
+// exporter, which sends the tenant ID in Header and tags the resource with
+// tenant.id.
+type TenantsConfig struct {
+	// Header defaults to X-Scope-OrgID.
+	Header    string                    `json:"header,omitempty"`
+	Overrides map[string]TenantOverride `json:"overrides,omitempty"`
+}
+
+// TenantOverride adds OTLP export headers and resource attributes for one
+// tenant.
+type TenantOverride struct {
+	Headers            map[string]string `json:"headers,omitempty"`
+	ResourceAttributes map[string]string `json:"resourceAttributes,omitempty"`
+}
+
+// ListenerConfig describes one HTTP server and the routes mounted on it.
+// Addr is a TCP address such as ":8080", or a unix socket path written as
+// "unix:/path/to.sock".
+type ListenerConfig struct {
+	Name   string   `json:"name"`
+	Addr   string   `json:"addr"`
+	Routes []string `json:"routes"`
+}
+
+const (
+	defaultHTTPAddr    = ":80"
+	defaultMetricsAddr = ":8080"
+)
+
+// DefaultListeners returns the "http" listener on :80, serving the
+// catch-all increment handler, and the "metrics" listener on :8080, serving
+// everything else.
+func DefaultListeners() []ListenerConfig {
+	return []ListenerConfig{
+		{
+			Name:   "http",
+			Addr:   defaultHTTPAddr,
+			Routes: []string{RouteIncrement},
+		},
+		{
+			Name:   "metrics",
+			Addr:   defaultMetricsAddr,
+			Routes: []string{RouteMetrics, RouteTenantMetrics, RouteTargets, RouteHTTPSD, RouteTargetAllocator, RouteVariants, RouteFlush, RouteForceRestart, RouteAPI, RouteDashboard, RouteGRPC, RouteOpenAPI},
+		},
+	}
+}
+
+// LoadConfig reads the file named by CONFIG_FILE, if set. Without listeners
+// in the file, DefaultListeners is used with its addresses overridden by
+// HTTP_ADDR and METRICS_ADDR. REPLAY_FILE, CONTROL_RECORD_FILE and
+// CONTROL_REPLAY_FILE override the files to replay and record, and
+// CLUSTER_SERVICE turns on cluster mode with that service. FLEET_PEERS, a
+// comma-separated list, and FLEET_SERVICE set the fleet's replicas.
+// BAGGAGE_KEYS, also comma-separated, is the baggage allowlist.
+func LoadConfig() (Config, error) {
+	var cfg Config
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("reading config file: %w", err)
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("parsing config file %s: %w", path, err)
+		}
+	}
+	if len(cfg.Listeners) == 0 {
+		cfg.Listeners = DefaultListeners()
+		cfg.Listeners[0].Addr = EnvString("HTTP_ADDR", defaultHTTPAddr)
+		cfg.Listeners[1].Addr = EnvString("METRICS_ADDR", defaultMetricsAddr)
+	}
+	if file := os.Getenv("REPLAY_FILE"); file != "" {
+		if cfg.Replay == nil {
+			cfg.Replay = &ReplayConfig{}
+		}
+		cfg.Replay.File = file
+	}
+	cfg.Control.RecordFile = EnvString("CONTROL_RECORD_FILE", cfg.Control.RecordFile)
+	cfg.Control.ReplayFile = EnvString("CONTROL_REPLAY_FILE", cfg.Control.ReplayFile)
+	if service := os.Getenv("CLUSTER_SERVICE"); service != "" {
+		if cfg.Cluster == nil {
+			cfg.Cluster = &ClusterConfig{}
+		}
+		cfg.Cluster.Service = service
+	}
+	if peers := os.Getenv("FLEET_PEERS"); peers != "" {
+		cfg.Fleet.Peers = strings.Split(peers, ",")
+	}
+	cfg.Fleet.Service = EnvString("FLEET_SERVICE", cfg.Fleet.Service)
+	if keys := os.Getenv("BAGGAGE_KEYS"); keys != "" {
+		cfg.Baggage.Keys = strings.Split(keys, ",")
+	}
+	cfg.Semconv.Version = EnvString("SEMCONV_VERSION", cfg.Semconv.Version)
+	return cfg, cfg.validate()
+}
+
+func (c Config) validate() error {
+	names := make(map[string]bool, len(c.Listeners))
+	for _, lc := range c.Listeners {
+		if lc.Name == "" {
+			return fmt.Errorf("listener with addr %q has no name", lc.Addr)
+		}
+		if names[lc.Name] {
+			return fmt.Errorf("duplicate listener name %q", lc.Name)
+		}
+		names[lc.Name] = true
+		if lc.Addr == "" {
+			return fmt.Errorf("listener %q has no addr", lc.Name)
+		}
+		// The mux panics on a pattern registered twice.
+		mounted := make(map[string]string)
+		for _, r := range lc.Routes {
+			patterns, ok := routePatterns[r]
+			if !ok {
+				return fmt.Errorf("listener %q: unknown route %q", lc.Name, r)
+			}
+			for _, p := range patterns {
+				if prev, dup := mounted[p]; dup && prev == r {
+					return fmt.Errorf("listener %q: route %q is listed twice", lc.Name, r)
+				} else if dup {
+					return fmt.Errorf("listener %q: routes %q and %q are both mounted at %q", lc.Name, prev, r, p)
+				}
+				mounted[p] = r
+			}
+		}
+	}
+	if churn := c.Targets.Churn; churn != nil && (churn.Min < 0 || churn.Max < churn.Min) {
+		return fmt.Errorf("targets churn needs 0 <= min <= max, got %d and %d", churn.Min, churn.Max)
+	}
+	if limit := maxTargets(c.Limits); limit > 0 {
+		if c.Targets.Count > limit {
+			return fmt.Errorf("targets count %d is over the limit of %d", c.Targets.Count, limit)
+		}
+		if churn := c.Targets.Churn; churn != nil && churn.Max > limit {
+			return fmt.Errorf("targets churn max %d is over the limit of %d", churn.Max, limit)
+		}
+	}
+	for _, v := range c.SDK.Views {
+		if err := v.validate(); err != nil {
+			return err
+		}
+	}
+	if r := c.SDK.Reader; r != "" && r != ReaderPeriodic && r != ReaderManual {
+		return fmt.Errorf("unknown reader %q, expected %s or %s", r, ReaderPeriodic, ReaderManual)
+	}
+	if c.SDK.CardinalityLimit < 0 {
+		return fmt.Errorf("cardinality limit must not be negative, got %d", c.SDK.CardinalityLimit)
+	}
+	if err := c.ScrapeTimestamps.validate(); err != nil {
+		return err
+	}
+	for _, v := range c.Variants {
+		if err := v.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Replay != nil {
+		if err := c.Replay.validate(); err != nil {
+			return err
+		}
+	}
+	if err := c.Control.validate(); err != nil {
+		return err
+	}
+	if c.Cluster != nil {
+		if err := c.Cluster.validate(); err != nil {
+			return err
+		}
+	}
+	if err := c.Workload.validate(); err != nil {
+		return err
+	}
+	if err := c.Tracing.validate(); err != nil {
+		return err
+	}
+	if err := c.Downstream.validate(); err != nil {
+		return err
+	}
+	if err := c.Baggage.validate(); err != nil {
+		return err
+	}
+	if err := c.Semconv.validate(); err != nil {
+		return err
+	}
+	return c.Limits.validate()
+}
+
+// EnvString returns the environment variable key, or def if it is unset or
+// empty.
+func EnvString(key, def string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return def
+}
+
+// EnvBool returns the environment variable key parsed as a bool, or def if
+// it is unset or not a bool.
+func EnvBool(key string, def bool) bool {
+	if v, ok := os.LookupEnv(key); ok {
+		if parsed, err := strconv.ParseBool(v); err == nil {
+			return parsed
+		}
+	}
+	return def
+}
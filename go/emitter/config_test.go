@@ -0,0 +1,74 @@
+package emitter
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestConfigValidateListeners(t *testing.T) {
+	tests := []struct {
+		name      string
+		listeners []ListenerConfig
+		wantErr   string
+	}{
+		{
+			name:      "defaults",
+			listeners: DefaultListeners(),
+		},
+		{
+			name:      "unknown route",
+			listeners: []ListenerConfig{{Name: "a", Addr: ":1", Routes: []string{"nope"}}},
+			wantErr:   `unknown route "nope"`,
+		},
+		{
+			name:      "route listed twice",
+			listeners: []ListenerConfig{{Name: "a", Addr: ":1", Routes: []string{RouteMetrics, RouteAPI, RouteMetrics}}},
+			wantErr:   `route "metrics" is listed twice`,
+		},
+		{
+			name: "same route on two listeners",
+			listeners: []ListenerConfig{
+				{Name: "a", Addr: ":1", Routes: []string{RouteMetrics}},
+				{Name: "b", Addr: ":2", Routes: []string{RouteMetrics}},
+			},
+		},
+		{
+			name: "duplicate listener name",
+			listeners: []ListenerConfig{
+				{Name: "a", Addr: ":1"},
+				{Name: "a", Addr: ":2"},
+			},
+			wantErr: `duplicate listener name "a"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Config{Listeners: tt.listeners}.validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestRoutePatternsMount checks every route can be mounted on one mux, so
+// a validated config never makes the mux panic.
+func TestRoutePatternsMount(t *testing.T) {
+	lc := ListenerConfig{Name: "all", Addr: ":1"}
+	handlers := make(map[string]http.Handler)
+	for name := range routePatterns {
+		lc.Routes = append(lc.Routes, name)
+		handlers[name] = http.NotFoundHandler()
+	}
+	if err := (Config{Listeners: []ListenerConfig{lc}}).validate(); err != nil {
+		t.Fatal(err)
+	}
+	newMux(lc, handlers)
+}
@@ -0,0 +1,348 @@
+package emitter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"eriktestapp/controlpb"
+
+	"google.golang.org/grpc/status"
+)
+
+// ControlConfig records control requests to a file and replays such a
+// recording at startup.
+type ControlConfig struct {
+	// RecordFile has every control request appended to it as a JSON line.
+	RecordFile string `json:"recordFile,omitempty"`
+	// ReplayFile is a recording replayed against the app at startup.
+	ReplayFile string `json:"replayFile,omitempty"`
+	ControlReplayOptions
+}
+
+// ControlReplayOptions controls how a control recording is replayed.
+type ControlReplayOptions struct {
+	// Cadence and Speed work as for OTLP replays.
+	Cadence string  `json:"cadence,omitempty"`
+	Speed   float64 `json:"speed,omitempty"`
+	// Faults replays restarts too, which exit the process; they are
+	// skipped otherwise.
+	Faults bool `json:"faults,omitempty"`
+}
+
+// ControlRecord is one recorded control request: a POST to a series path,
+// /api/, /flush or /forcerestart, or a gRPC call other than List* and Get*.
+// Reads are not recorded.
+type ControlRecord struct {
+	Time   time.Time `json:"time"`
+	Route  string    `json:"route"`
+	Method string    `json:"method"`
+	// URL is the path and query.
+	URL string `json:"url"`
+	// Tenant is the tenant header, if any.
+	Tenant      string `json:"tenant,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	// Body holds JSON bodies as is and Data any other body. A gRPC call's
+	// URL is its full method and Body its request as protojson.
+	Body json.RawMessage `json:"body,omitempty"`
+	Data []byte          `json:"data,omitempty"`
+	// Status is the response status, which a replay is checked against.
+	Status int `json:"status"`
+	// Code is the status code of a gRPC call, checked instead of Status.
+	Code string `json:"code,omitempty"`
+}
+
+func (o ControlReplayOptions) validate() error {
+	return ReplayOptions{Cadence: o.Cadence, Speed: o.Speed}.validate()
+}
+
+func (c ControlConfig) validate() error {
+	return c.ControlReplayOptions.validate()
+}
+
+// controlRecorder appends ControlRecords to a file.
+type controlRecorder struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+func openControlRecorder(path string) (*controlRecorder, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &controlRecorder{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (c *controlRecorder) write(rec ControlRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.enc.Encode(rec); err != nil {
+		log.Printf("Recording control request: %v", err)
+	}
+}
+
+func (c *controlRecorder) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.f.Close()
+}
+
+type replayingKey struct{}
+
+// recordControl wraps a control route's handler so its requests are
+// appended to the control recording, unless they are being replayed.
+func (a *App) recordControl(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.controlLog == nil || r.Method == http.MethodGet || r.Method == http.MethodHead ||
+			strings.HasPrefix(r.URL.Path, "/api/control/") || r.Context().Value(replayingKey{}) != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := ControlRecord{
+			Time:        time.Now(),
+			Route:       route,
+			Method:      r.Method,
+			URL:         r.URL.RequestURI(),
+			Tenant:      r.Header.Get(a.tenantHeader()),
+			ContentType: r.Header.Get("Content-Type"),
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			if !writeBodyTooLargeError(w, err) {
+				http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			}
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if json.Valid(body) {
+			rec.Body = body
+		} else if len(body) > 0 {
+			rec.Data = body
+		}
+		sw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		rec.Status = sw.status
+		a.controlLog.write(rec)
+	})
+}
+
+// readControlRecords parses a control recording.
+func readControlRecords(r io.Reader) ([]ControlRecord, error) {
+	var recs []ControlRecord
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, maxReplayMessage)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var rec ControlRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if _, ok := routePatterns[rec.Route]; !ok {
+			return nil, fmt.Errorf("line %d: unknown route %q", line, rec.Route)
+		}
+		recs = append(recs, rec)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(recs) == 0 {
+		return nil, errors.New("no control requests found")
+	}
+	return recs, nil
+}
+
+// isFault reports whether replaying rec would trigger a fault.
+func (rec ControlRecord) isFault() bool {
+	if rec.Route == RouteForceRestart {
+		return true
+	}
+	return strings.HasPrefix(rec.URL, "/api/faults") || rec.URL == controlpb.Control_TriggerFault_FullMethodName
+}
+
+// ReplayControl replays a control recording against the app through the
+// handlers that served it, without the rate limits and forwarding in front
+// of them, returning once it is done. Requests whose
+// status differs from the recorded one count as failed. gRPC calls are
+// made to the Control service directly.
+func (a *App) ReplayControl(ctx context.Context, source string, r io.Reader, opts ControlReplayOptions) (ReplayRecord, error) {
+	if err := opts.validate(); err != nil {
+		return ReplayRecord{}, err
+	}
+	recs, err := readControlRecords(r)
+	if err != nil {
+		return ReplayRecord{}, err
+	}
+	return a.replayControl(ctx, source, recs, opts), nil
+}
+
+func (a *App) replayControl(ctx context.Context, source string, recs []ControlRecord, opts ControlReplayOptions) (rec ReplayRecord) {
+	rec = ReplayRecord{Source: source, Started: time.Now()}
+	defer func() {
+		rec.Finished = time.Now()
+		a.replays.add(rec)
+		log.Printf("Replayed %d control requests from %s, %d with a different status", rec.Requests, source, rec.Failed)
+	}()
+
+	speed := replaySpeed(opts.Cadence, opts.Speed)
+	ctx = context.WithValue(ctx, replayingKey{}, true)
+	for i, cr := range recs {
+		if i > 0 {
+			if err := a.replayWait(ctx, recs[i].Time.Sub(recs[i-1].Time), speed); err != nil {
+				rec.Error = err.Error()
+				return rec
+			}
+		}
+		if cr.isFault() && !opts.Faults {
+			log.Printf("Skipping recorded fault %s %s", cr.Method, cr.URL)
+			continue
+		}
+		if cr.Route == RouteGRPC {
+			rec.Requests++
+			if code := status.Code(a.replayGRPC(ctx, cr)).String(); code != cr.Code {
+				rec.Failed++
+				rec.Error = fmt.Sprintf("%s: got code %s, recorded %s", cr.URL, code, cr.Code)
+				log.Print(rec.Error)
+			}
+			continue
+		}
+		body := []byte(cr.Body)
+		if cr.Data != nil {
+			body = cr.Data
+		}
+		req, err := http.NewRequestWithContext(ctx, cr.Method, cr.URL, bytes.NewReader(body))
+		if err != nil {
+			rec.Error = err.Error()
+			return rec
+		}
+		req.RemoteAddr = "replay"
+		if cr.Tenant != "" {
+			req.Header.Set(a.tenantHeader(), cr.Tenant)
+		}
+		if cr.ContentType != "" {
+			req.Header.Set("Content-Type", cr.ContentType)
+		}
+		resp := &controlResponse{header: make(http.Header)}
+		if h, ok := a.controlHandlers[cr.Route]; ok {
+			h.ServeHTTP(resp, req)
+		} else {
+			http.Error(resp, fmt.Sprintf("route %s is not a control route", cr.Route), http.StatusNotFound)
+		}
+		rec.Requests++
+		if cr.Status != 0 && resp.statusCode() != cr.Status {
+			rec.Failed++
+			rec.Error = fmt.Sprintf("%s %s: got status %d, recorded %d: %s", cr.Method, cr.URL, resp.statusCode(), cr.Status, strings.TrimSpace(resp.body.String()))
+			log.Print(rec.Error)
+		}
+	}
+	return rec
+}
+
+// maxReplayResponseBody caps how much of a replayed response is kept for
+// the error reported when its status differs.
+const maxReplayResponseBody = 512
+
+// controlResponse is the ResponseWriter replayed HTTP requests are served
+// to. It keeps the status and the start of the body.
+type controlResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *controlResponse) Header() http.Header { return r.header }
+
+func (r *controlResponse) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+}
+
+func (r *controlResponse) Write(p []byte) (int, error) {
+	r.WriteHeader(http.StatusOK)
+	if n := maxReplayResponseBody - r.body.Len(); n > 0 {
+		r.body.Write(p[:min(n, len(p))])
+	}
+	return len(p), nil
+}
+
+func (r *controlResponse) statusCode() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+// loadControlReplay parses the configured recording, returning the replay
+// to run in the background.
+func (a *App) loadControlReplay(cfg ControlConfig) (func(), error) {
+	f, err := os.Open(cfg.ReplayFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	recs, err := readControlRecords(f)
+	if err != nil {
+		return nil, fmt.Errorf("control replay %s: %w", cfg.ReplayFile, err)
+	}
+	return func() {
+		log.Printf("Replaying %d control requests from %s", len(recs), cfg.ReplayFile)
+		a.replayControl(context.Background(), cfg.ReplayFile, recs, cfg.ControlReplayOptions)
+	}, nil
+}
+
+// handleAPIControlReplay replays the control recording in the body in the
+// background.
+func (a *App) handleAPIControlReplay(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := ControlReplayOptions{
+		Cadence: q.Get("cadence"),
+		Faults:  q.Get("faults") == "true",
+	}
+	var ok bool
+	if opts.Speed, ok = parseSpeed(w, q.Get("speed")); !ok {
+		return
+	}
+	if err := opts.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	recs, err := readControlRecords(r.Body)
+	if err != nil {
+		if !writeBodyTooLargeError(w, err) {
+			http.Error(w, "Invalid control recording: "+err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	source := q.Get("source")
+	if source == "" {
+		source = "api"
+	}
+	log.Printf("Replaying %d control requests from %s", len(recs), source)
+	// The replay outlives this request, so it must not use its context.
+	go a.replayControl(context.Background(), source, recs, opts)
+	writeJSON(w, http.StatusAccepted, controlReplayResponse{Source: source, Requests: len(recs), ControlReplayOptions: opts})
+}
+
+// handleAPIControlRecording serves the control recording so far.
+func (a *App) handleAPIControlRecording(w http.ResponseWriter, r *http.Request) {
+	if a.controlLog == nil {
+		http.Error(w, "Control requests are not being recorded", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	http.ServeFile(w, r, a.controlLog.f.Name())
+}
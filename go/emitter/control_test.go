@@ -0,0 +1,106 @@
+package emitter
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"eriktestapp/controlpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// grpcClient serves a's Control service on a local port.
+func grpcClient(t *testing.T, a *App) controlpb.ControlClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := a.newGRPCServer()
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return controlpb.NewControlClient(conn)
+}
+
+func TestRecordGRPCControl(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "control.jsonl")
+	a := newTestApp(t, Config{Control: ControlConfig{RecordFile: file}})
+	client := grpcClient(t, a)
+
+	if _, err := client.Increment(ctx, &controlpb.IncrementRequest{Path: "/a", IncrementBy: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ListWorkers(ctx, &controlpb.ListWorkersRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.StopWorker(ctx, &controlpb.StopWorkerRequest{Path: "/a"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("StopWorker: %v, want NotFound", err)
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	recs, err := readControlRecords(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ url, code string }{
+		{controlpb.Control_Increment_FullMethodName, "OK"},
+		{controlpb.Control_StopWorker_FullMethodName, "NotFound"},
+	}
+	if len(recs) != len(want) {
+		t.Fatalf("recorded %+v, want %d records", recs, len(want))
+	}
+	for i, w := range want {
+		if recs[i].Route != RouteGRPC || recs[i].URL != w.url || recs[i].Code != w.code {
+			t.Errorf("record %d = %+v, want %s with code %s", i, recs[i], w.url, w.code)
+		}
+	}
+
+	b := newTestApp(t, Config{})
+	rec := b.replayControl(ctx, "test", recs, ControlReplayOptions{Cadence: CadenceASAP})
+	if rec.Requests != 2 || rec.Failed != 0 {
+		t.Errorf("replay = %+v, want 2 requests and none failed", rec)
+	}
+	if got := b.ledger.total(seriesKey{"", "/a"}); got != 2 {
+		t.Errorf("replayed total = %d, want 2", got)
+	}
+}
+
+// TestReplayControlBypassesLimits checks replayed requests go straight to
+// the handlers that served them, not through the rate limiter.
+func TestReplayControlBypassesLimits(t *testing.T) {
+	a := newTestApp(t, Config{Limits: LimitsConfig{RequestsPerSecond: 1}})
+	var recs []ControlRecord
+	for range 3 {
+		recs = append(recs,
+			ControlRecord{Route: RouteIncrement, Method: http.MethodPost, URL: "/a", Body: json.RawMessage(`{"incrementBy":1}`), Status: http.StatusOK},
+			ControlRecord{Route: RouteAPI, Method: http.MethodPost, URL: "/api/increment", Body: json.RawMessage(`{"path":"/b","incrementBy":1}`), Status: http.StatusOK},
+		)
+	}
+	rec := a.replayControl(context.Background(), "test", recs, ControlReplayOptions{Cadence: CadenceASAP})
+	if rec.Requests != len(recs) || rec.Failed != 0 {
+		t.Errorf("replay = %+v, want %d requests and none failed", rec, len(recs))
+	}
+	for _, path := range []string{"/a", "/b"} {
+		if got := a.ledger.total(seriesKey{"", path}); got != 3 {
+			t.Errorf("replayed total for %s = %d, want 3", path, got)
+		}
+	}
+}
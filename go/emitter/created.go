@@ -0,0 +1,69 @@
+package emitter
+
+import (
+	"context"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// startTimeExporter makes cumulative sums agree with the created timestamps
+// Prometheus exposes. The SDK starts every series of an instrument at the
+// instrument's creation and cannot reset a counter, so this exporter fixes
+// up the points it exports.
+//
+// The path counter's points take their series' created time from the
+// ledger, and their values drop whatever their attribute set counted before
+// the last reset. Variant counters' points start at the variant's
+// declaration. Streams are matched by the instrument they came from, so
+// views renaming them do not turn this off.
+type startTimeExporter struct {
+	sdkmetric.Exporter
+	app    *App
+	tenant string
+}
+
+func (e startTimeExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			name := e.app.cfg.SDK.instrumentName(sm.Scope.Name, m.Name)
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				if data.Temporality == metricdata.CumulativeTemporality && sm.Scope.Name == scopeName && name == otlpSumCounterName {
+					e.alignPathCounter(data.DataPoints)
+				}
+			case metricdata.Sum[float64]:
+				if data.Temporality == metricdata.CumulativeTemporality && e.tenant == "" {
+					e.alignVariant(sm.Scope.Name, name, data.DataPoints)
+				}
+			}
+		}
+	}
+	return e.Exporter.Export(ctx, rm)
+}
+
+func (e startTimeExporter) alignPathCounter(points []metricdata.DataPoint[int64]) {
+	for i := range points {
+		dp := &points[i]
+		path, _ := dp.Attributes.Value("path")
+		created, offset, ok := e.app.ledger.start(seriesKey{e.tenant, path.AsString()}, dp.Attributes)
+		if !ok {
+			continue
+		}
+		dp.StartTime = created
+		dp.Value = max(dp.Value-offset, 0)
+	}
+}
+
+func (e startTimeExporter) alignVariant(scope, name string, points []metricdata.DataPoint[float64]) {
+	if scope == scopeName {
+		scope = ""
+	}
+	created, ok := e.app.variantCreated(scope, name)
+	if !ok {
+		return
+	}
+	for i := range points {
+		points[i].StartTime = created
+	}
+}
@@ -0,0 +1,89 @@
+package emitter
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"testing"
+)
+
+func TestStartTimeAlignment(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		views []ViewConfig
+		want  string
+	}{
+		{"no views", nil, otlpSumCounterName},
+		{"renamed", []ViewConfig{{Instrument: otlpSumCounterName, Name: "renamed_total"}}, "renamed_total"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			exp := &captureExporter{}
+			a := newTestApp(t, Config{SDK: SDKConfig{Reader: ReaderManual, Views: tc.views}}, WithExporter(exp))
+			if err := a.Increment("/a", IncrementRequest{IncrementBy: 5}); err != nil {
+				t.Fatal(err)
+			}
+			reset, _ := a.ResetSeries("", "/a")
+			if err := a.Increment("/a", IncrementRequest{IncrementBy: 2}); err != nil {
+				t.Fatal(err)
+			}
+			if err := a.Flush(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			points := exp.int64Sum(t, tc.want)
+			if len(points) != 1 {
+				t.Fatalf("%d points, want 1", len(points))
+			}
+			if got := points[0].Value; got != 2 {
+				t.Errorf("value = %d, want 2 counted since the reset", got)
+			}
+			if got := points[0].StartTime; !got.Equal(reset.Created) {
+				t.Errorf("start time = %v, want the reset's created time %v", got, reset.Created)
+			}
+		})
+	}
+}
+
+// TestStartTimeAlignmentBaggage checks resets are applied per attribute
+// set when baggage splits a path's OTLP series.
+func TestStartTimeAlignmentBaggage(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		views []ViewConfig
+		want  map[string]int64
+	}{
+		{"split", nil, map[string]int64{"x": 1, "": 0}},
+		{"merged by a view", []ViewConfig{{Instrument: otlpSumCounterName, DenyAttributes: []string{"customer"}}}, map[string]int64{"": 1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			exp := &captureExporter{}
+			a := newTestApp(t, Config{
+				SDK:     SDKConfig{Reader: ReaderManual, Views: tc.views},
+				Baggage: BaggageConfig{Keys: []string{"customer"}},
+			}, WithExporter(exp))
+			h := a.Handler("http")
+			for _, req := range []struct{ body, baggage string }{
+				{`{"incrementBy":3}`, "customer=x"},
+				{`{"incrementBy":5}`, ""},
+			} {
+				if w := postIncrement(h, "/a", req.body, "baggage", req.baggage); w.Code != http.StatusOK {
+					t.Fatalf("POST /a: %d %s", w.Code, w.Body)
+				}
+			}
+			a.ResetSeries("", "/a")
+			if w := postIncrement(h, "/a", `{"incrementBy":1}`, "baggage", "customer=x"); w.Code != http.StatusOK {
+				t.Fatalf("POST /a: %d %s", w.Code, w.Body)
+			}
+			if err := a.Flush(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]int64)
+			for _, dp := range exp.int64Sum(t, otlpSumCounterName) {
+				customer, _ := dp.Attributes.Value("customer")
+				got[customer.AsString()] = dp.Value
+			}
+			if !maps.Equal(got, tc.want) {
+				t.Errorf("values by customer = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
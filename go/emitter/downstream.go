@@ -0,0 +1,208 @@
+package emitter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const (
+	defaultMaxHops           = 5
+	defaultDownstreamTimeout = 5 * time.Second
+	// hopsHeader counts the downstream calls a request is nested in, so
+	// replicas calling each other cannot loop forever.
+	hopsHeader = "X-Erik-Hops"
+)
+
+// DownstreamConfig makes increments call other services, typically other
+// replicas of this app, through an instrumented HTTP client. Each call is
+// a client span and shows up in the otelhttp client metrics, and trace
+// context and baggage are propagated, so chains of replicas produce
+// multi-hop traces and service-graph edges.
+type DownstreamConfig struct {
+	// Calls are made in order. Change them with POST /api/downstream.
+	Calls []DownstreamCall `json:"calls"`
+	// MaxHops is how deep a chain of calls may get. Defaults to 5.
+	MaxHops int `json:"maxHops,omitempty"`
+	// TimeoutMs bounds each call. Defaults to 5000.
+	TimeoutMs int `json:"timeoutMs,omitempty"`
+}
+
+// DownstreamCall is one outbound call made for the increments of the
+// series paths matching Path, both by POSTs to the "http" listener and by
+// interval worker ticks. It carries the increment as an IncrementRequest
+// and the tenant in the tenant header. A failed call fails the POST with
+// 502 without applying it; a worker tick is applied regardless.
+type DownstreamCall struct {
+	// Path is a path.Match pattern; empty matches every path.
+	Path string `json:"path,omitempty"`
+	// URL is called as is, or with the series path appended if it ends
+	// in a slash.
+	URL string `json:"url"`
+	// Method defaults to POST.
+	Method string `json:"method,omitempty"`
+}
+
+func (c DownstreamConfig) validate() error {
+	if c.MaxHops < 0 || c.TimeoutMs < 0 {
+		return fmt.Errorf("downstream max hops and timeout must not be negative, got %d and %d", c.MaxHops, c.TimeoutMs)
+	}
+	for _, call := range c.Calls {
+		if err := call.validate(); err != nil {
+			return fmt.Errorf("downstream call %q: %w", call.URL, err)
+		}
+	}
+	return nil
+}
+
+func (c DownstreamCall) validate() error {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if _, err := path.Match(c.Path, ""); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (c DownstreamConfig) withDefaults() DownstreamConfig {
+	if c.Calls == nil {
+		c.Calls = []DownstreamCall{}
+	}
+	if c.MaxHops == 0 {
+		c.MaxHops = defaultMaxHops
+	}
+	if c.TimeoutMs == 0 {
+		c.TimeoutMs = int(defaultDownstreamTimeout / time.Millisecond)
+	}
+	return c
+}
+
+func (c DownstreamCall) matches(seriesPath string) bool {
+	if c.Path == "" {
+		return true
+	}
+	ok, _ := path.Match(c.Path, seriesPath)
+	return ok
+}
+
+// SetDownstream replaces the downstream calls and returns the settings now
+// in effect.
+func (a *App) SetDownstream(cfg DownstreamConfig) (DownstreamConfig, error) {
+	if err := cfg.validate(); err != nil {
+		return cfg, err
+	}
+	cfg = cfg.withDefaults()
+	a.downstream.Store(&cfg)
+	return cfg, nil
+}
+
+// Downstream returns the downstream calls.
+func (a *App) Downstream() DownstreamConfig {
+	return *a.downstream.Load()
+}
+
+// newDownstreamClient builds the instrumented client downstream calls are
+// made with.
+func (a *App) newDownstreamClient() *http.Client {
+	return &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport,
+		otelhttp.WithTracerProvider(a.TracerProvider()),
+		otelhttp.WithMeterProvider(a.defaultTenant.meterProvider),
+		otelhttp.WithPropagators(propagator),
+	)}
+}
+
+// requestHops returns how many downstream calls deep r is.
+func requestHops(r *http.Request) int {
+	n, _ := strconv.Atoi(r.Header.Get(hopsHeader))
+	return max(n, 0)
+}
+
+// callDownstream makes the downstream calls for an increment of incBy to
+// seriesPath, hops calls deep, stopping at the first failure.
+func (a *App) callDownstream(ctx context.Context, t *tenant, seriesPath string, incBy, hops int) error {
+	cfg := a.downstream.Load()
+	if len(cfg.Calls) == 0 || hops >= cfg.MaxHops {
+		return nil
+	}
+	body, err := json.Marshal(IncrementRequest{IncrementBy: incBy})
+	if err != nil {
+		return err
+	}
+	for _, call := range cfg.Calls {
+		if !call.matches(seriesPath) {
+			continue
+		}
+		if err := a.callOne(ctx, cfg, call, t, seriesPath, body, hops); err != nil {
+			return fmt.Errorf("%s %s: %w", call.method(), call.URL, err)
+		}
+	}
+	return nil
+}
+
+func (c DownstreamCall) method() string {
+	if c.Method == "" {
+		return http.MethodPost
+	}
+	return c.Method
+}
+
+func (a *App) callOne(ctx context.Context, cfg *DownstreamConfig, call DownstreamCall, t *tenant, seriesPath string, body []byte, hops int) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(cfg.TimeoutMs)*time.Millisecond)
+	defer cancel()
+	target := call.URL
+	if strings.HasSuffix(target, "/") {
+		target = strings.TrimSuffix(target, "/") + seriesPath
+	}
+	req, err := http.NewRequestWithContext(ctx, call.method(), target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(hopsHeader, strconv.Itoa(hops+1))
+	if t.id != "" {
+		req.Header.Set(a.tenantHeader(), t.id)
+	}
+	resp, err := a.downstreamClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
+
+// callDownstream makes a worker tick's downstream calls as a new trace.
+func (w *intervalWorker) callDownstream() {
+	a := w.app
+	if len(a.downstream.Load().Calls) == 0 {
+		return
+	}
+	ctx, span := a.tracer().Start(context.Background(), "worker tick")
+	defer span.End()
+	if err := a.callDownstream(ctx, w.tenant, w.path, w.incBy, 0); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		log.Printf("Worker for path %s: downstream call failed: %v", w.path, err)
+	}
+}
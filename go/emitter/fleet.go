@@ -0,0 +1,200 @@
+package emitter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FleetConfig names the replicas whose ledgers GET /api/fleet/series
+// merges. Without Peers or Service, cluster mode's members are used, and
+// outside cluster mode only this replica's ledger.
+type FleetConfig struct {
+	// Peers are the host:port or base URLs of each replica's API listener.
+	Peers []string `json:"peers,omitempty"`
+	// Service is resolved to the replicas' addresses, which are asked at
+	// this replica's API port.
+	Service string `json:"service,omitempty"`
+}
+
+// InstanceLedger is one replica's expected totals, as served by GET
+// /api/series.
+type InstanceLedger struct {
+	// Instance is the replica's service.instance.id.
+	Instance string `json:"instance"`
+	// Process is random per process, telling replicas apart when they
+	// share an instance ID, as they do without POD_NAME.
+	Process string        `json:"process,omitempty"`
+	Series  []SeriesTotal `json:"series"`
+}
+
+// FleetPeer is the outcome of asking one replica for its ledger.
+type FleetPeer struct {
+	Peer     string `json:"peer"`
+	Instance string `json:"instance,omitempty"`
+	Series   int    `json:"series"`
+	Error    string `json:"error,omitempty"`
+}
+
+// FleetTotals are the expected totals summed across replicas, i.e. what
+// the backend should hold after aggregating away service.instance.id.
+type FleetTotals struct {
+	// Complete is false if any peer could not be asked, in which case the
+	// totals are too low.
+	Complete bool          `json:"complete"`
+	Peers    []FleetPeer   `json:"peers"`
+	Series   []SeriesTotal `json:"series"`
+}
+
+// localLedger is this replica's InstanceLedger.
+func (a *App) localLedger() InstanceLedger {
+	return InstanceLedger{Instance: a.instanceID, Process: a.processID, Series: a.ledger.snapshot()}
+}
+
+// fleetPeers returns the base URLs of the replicas to ask.
+func (a *App) fleetPeers(ctx context.Context) ([]string, error) {
+	cfg := a.cfg.Fleet
+	var hosts []string
+	switch {
+	case len(cfg.Peers) > 0:
+		hosts = cfg.Peers
+	case cfg.Service != "":
+		addrs, err := net.DefaultResolver.LookupHost(ctx, cfg.Service)
+		if err != nil {
+			return nil, err
+		}
+		hosts = addrs
+	case a.cluster != nil:
+		hosts = a.cluster.snapshot().Members
+	}
+	var peers []string
+	for _, h := range hosts {
+		if strings.Contains(h, "://") {
+			peers = append(peers, strings.TrimSuffix(h, "/"))
+			continue
+		}
+		if _, _, err := net.SplitHostPort(h); err != nil {
+			// A bare address gets this replica's API port.
+			if h, ok := a.peerHost(h, RouteAPI); ok {
+				peers = append(peers, "http://"+h)
+			}
+			continue
+		}
+		peers = append(peers, "http://"+h)
+	}
+	return peers, nil
+}
+
+func fetchLedger(ctx context.Context, peer string) (InstanceLedger, error) {
+	var l InstanceLedger
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, peer+"/api/series", nil)
+	if err != nil {
+		return l, err
+	}
+	resp, err := peerClient.Do(req)
+	if err != nil {
+		return l, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return l, fmt.Errorf("status %s", resp.Status)
+	}
+	return l, json.NewDecoder(resp.Body).Decode(&l)
+}
+
+// FleetTotals asks every peer for its ledger and sums them per series,
+// together with this replica's. Each process is counted once, however many
+// peers answer for it, so listing this replica among the peers is
+// harmless; replicas sharing an instance ID are still counted apart. A
+// series' created time is the earliest of any replica.
+func (a *App) FleetTotals(ctx context.Context) (FleetTotals, error) {
+	peers, err := a.fleetPeers(ctx)
+	if err != nil {
+		return FleetTotals{}, err
+	}
+	results := make([]FleetPeer, len(peers))
+	ledgers := make([]InstanceLedger, len(peers))
+	var wg sync.WaitGroup
+	for i, p := range peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l, err := fetchLedger(ctx, p)
+			results[i] = FleetPeer{Peer: p, Instance: l.Instance, Series: len(l.Series)}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+			ledgers[i] = l
+		}()
+	}
+	wg.Wait()
+
+	local := a.localLedger()
+	out := FleetTotals{Complete: true, Peers: results}
+	seen := map[string]bool{local.identity(): true}
+	merged := make(map[seriesKey]*SeriesTotal)
+	add := func(l InstanceLedger) {
+		for _, s := range l.Series {
+			key := seriesKey{s.Tenant, s.Path}
+			m, ok := merged[key]
+			if !ok {
+				m = &SeriesTotal{Tenant: s.Tenant, Path: s.Path, Created: s.Created}
+				merged[key] = m
+			}
+			m.Total += s.Total
+			if !s.Created.IsZero() && (m.Created.IsZero() || s.Created.Before(m.Created)) {
+				m.Created = s.Created
+			}
+		}
+	}
+	add(local)
+	for i, r := range results {
+		if r.Error != "" {
+			out.Complete = false
+			continue
+		}
+		if id := ledgers[i].identity(); !seen[id] {
+			seen[id] = true
+			add(ledgers[i])
+		}
+	}
+
+	out.Series = make([]SeriesTotal, 0, len(merged))
+	for _, s := range merged {
+		out.Series = append(out.Series, *s)
+	}
+	sort.Slice(out.Series, func(i, j int) bool {
+		if out.Series[i].Tenant != out.Series[j].Tenant {
+			return out.Series[i].Tenant < out.Series[j].Tenant
+		}
+		return out.Series[i].Path < out.Series[j].Path
+	})
+	return out, nil
+}
+
+// identity tells the process that served l apart from others. Replicas
+// too old to report their process fall back to their instance ID.
+func (l InstanceLedger) identity() string {
+	if l.Process != "" {
+		return "process:" + l.Process
+	}
+	return "instance:" + l.Instance
+}
+
+func (a *App) handleAPISeries(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.localLedger())
+}
+
+func (a *App) handleAPIFleetSeries(w http.ResponseWriter, r *http.Request) {
+	totals, err := a.FleetTotals(r.Context())
+	if err != nil {
+		http.Error(w, "Finding peers: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeJSON(w, http.StatusOK, totals)
+}
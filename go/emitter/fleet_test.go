@@ -0,0 +1,46 @@
+package emitter
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestFleetTotalsSharedInstanceID checks replicas left with the default
+// instance ID are each counted, and this replica once however often it is
+// listed.
+func TestFleetTotalsSharedInstanceID(t *testing.T) {
+	var peers []string
+	for range 2 {
+		p := newTestApp(t, Config{})
+		if err := p.Increment("/a", IncrementRequest{IncrementBy: 2}); err != nil {
+			t.Fatal(err)
+		}
+		srv := httptest.NewServer(p.Handler("metrics"))
+		t.Cleanup(srv.Close)
+		peers = append(peers, srv.URL, srv.URL+"/")
+	}
+	a := newTestApp(t, Config{})
+	if err := a.Increment("/a", IncrementRequest{IncrementBy: 1}); err != nil {
+		t.Fatal(err)
+	}
+	self := httptest.NewServer(a.Handler("metrics"))
+	t.Cleanup(self.Close)
+	a.cfg.Fleet.Peers = append(peers, self.URL)
+
+	totals, err := a.FleetTotals(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !totals.Complete {
+		t.Errorf("incomplete: %+v", totals.Peers)
+	}
+	for _, p := range totals.Peers {
+		if p.Instance != defaultInstanceID {
+			t.Errorf("peer %s reports instance %q, want the shared %q", p.Peer, p.Instance, defaultInstanceID)
+		}
+	}
+	if len(totals.Series) != 1 || totals.Series[0].Total != 5 {
+		t.Errorf("series = %+v, want /a at 2+2+1", totals.Series)
+	}
+}
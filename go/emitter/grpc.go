@@ -0,0 +1,233 @@
+package emitter
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"eriktestapp/controlpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// controlServer implements the gRPC Control service on top of the same
+// helpers as the JSON API.
+type controlServer struct {
+	controlpb.UnimplementedControlServer
+	app *App
+}
+
+func (a *App) newGRPCServer() *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(a.recordGRPCControl),
+		grpc.MaxRecvMsgSize(int(a.limits.maxBodyBytes)),
+	)
+	controlpb.RegisterControlServer(srv, controlServer{app: a})
+	return srv
+}
+
+// recordGRPCControl appends calls to the control recording as
+// recordControl does for HTTP, skipping the List* and Get* reads.
+func (a *App) recordGRPCControl(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	method := info.FullMethod[strings.LastIndex(info.FullMethod, "/")+1:]
+	if a.controlLog == nil || strings.HasPrefix(method, "List") || strings.HasPrefix(method, "Get") {
+		return handler(ctx, req)
+	}
+	rec := ControlRecord{Time: time.Now(), Route: RouteGRPC, Method: http.MethodPost, URL: info.FullMethod, Tenant: a.callTenant(ctx, "")}
+	if m, ok := req.(proto.Message); ok {
+		body, err := protojson.Marshal(m)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "recording request: %v", err)
+		}
+		rec.Body = body
+	}
+	resp, err := handler(ctx, req)
+	rec.Code = status.Code(err).String()
+	a.controlLog.write(rec)
+	return resp, err
+}
+
+// replayGRPC calls the Control method of a recorded gRPC call, without the
+// interceptor so it is not recorded again.
+func (a *App) replayGRPC(ctx context.Context, cr ControlRecord) error {
+	if cr.Tenant != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(a.tenantHeader(), cr.Tenant))
+	}
+	method := strings.TrimPrefix(cr.URL, "/"+controlpb.Control_ServiceDesc.ServiceName+"/")
+	for _, m := range controlpb.Control_ServiceDesc.Methods {
+		if m.MethodName != method {
+			continue
+		}
+		_, err := m.Handler(controlServer{app: a}, ctx, func(req any) error {
+			return protojson.Unmarshal(cr.Body, req.(proto.Message))
+		}, nil)
+		return err
+	}
+	return status.Errorf(codes.Unimplemented, "unknown method %s", cr.URL)
+}
+
+func (s controlServer) Increment(ctx context.Context, req *controlpb.IncrementRequest) (*controlpb.IncrementResponse, error) {
+	if err := s.app.limits.allowRequest(peerAddress(ctx)); err != nil {
+		return nil, grpcError(err)
+	}
+	if err := checkPath(req.GetPath()); err != nil {
+		return nil, err
+	}
+	tenant := s.app.callTenant(ctx, req.GetTenant())
+	if err := s.app.checkOwner(ctx, seriesKey{tenant, req.GetPath()}); err != nil {
+		return nil, err
+	}
+	err := s.app.Increment(req.GetPath(), IncrementRequest{
+		IncrementBy:              int(req.GetIncrementBy()),
+		IncrementByPeriodic:      int(req.GetIncrementByPeriodic()),
+		IncrementIntervalSeconds: int(req.GetIncrementIntervalSeconds()),
+		Tenant:                   tenant,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &controlpb.IncrementResponse{
+		Tenant: tenant,
+		Path:   req.GetPath(),
+		Total:  s.app.ledger.total(seriesKey{tenant, req.GetPath()}),
+	}, nil
+}
+
+func (s controlServer) ListWorkers(context.Context, *controlpb.ListWorkersRequest) (*controlpb.ListWorkersResponse, error) {
+	resp := &controlpb.ListWorkersResponse{}
+	for _, w := range s.app.Workers() {
+		resp.Workers = append(resp.Workers, workerProto(w))
+	}
+	return resp, nil
+}
+
+func (s controlServer) StartWorker(ctx context.Context, req *controlpb.StartWorkerRequest) (*controlpb.Worker, error) {
+	if err := checkPath(req.GetPath()); err != nil {
+		return nil, err
+	}
+	tenant := s.app.callTenant(ctx, req.GetTenant())
+	if err := s.app.checkOwner(ctx, seriesKey{tenant, req.GetPath()}); err != nil {
+		return nil, err
+	}
+	incBy := int(req.GetIncrementBy())
+	if incBy < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "increment must not be negative, got %d", incBy)
+	}
+	if incBy == 0 {
+		incBy = defaultIncrementBy
+	}
+	intervalSecs := int(req.GetIntervalSeconds())
+	if intervalSecs <= 0 {
+		intervalSecs = defaultIntervalSecs
+	}
+	log.Printf("Starting interval worker for path %s: %d every %ds", req.GetPath(), incBy, intervalSecs)
+	t, err := s.app.tenant(tenant)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	w, err := s.app.setWorker(t, req.GetPath(), incBy, intervalSecs)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &controlpb.Worker{
+		Tenant:          t.id,
+		Path:            w.path,
+		IncrementBy:     int64(w.incBy),
+		IntervalSeconds: int64(w.incIntervalSecs),
+		StartedAt:       timestamppb.New(w.startedAt),
+	}, nil
+}
+
+func (s controlServer) StopWorker(ctx context.Context, req *controlpb.StopWorkerRequest) (*controlpb.StopWorkerResponse, error) {
+	tenant := s.app.callTenant(ctx, req.GetTenant())
+	if err := s.app.checkOwner(ctx, seriesKey{tenant, req.GetPath()}); err != nil {
+		return nil, err
+	}
+	if !s.app.StopWorker(tenant, req.GetPath()) {
+		return nil, status.Errorf(codes.NotFound, "no worker for path %q", req.GetPath())
+	}
+	log.Printf("Stopped interval worker for path %s", req.GetPath())
+	return &controlpb.StopWorkerResponse{}, nil
+}
+
+func (s controlServer) ListMetrics(context.Context, *controlpb.ListMetricsRequest) (*controlpb.ListMetricsResponse, error) {
+	resp := &controlpb.ListMetricsResponse{}
+	for _, m := range s.app.Metrics() {
+		resp.Metrics = append(resp.Metrics, &controlpb.MetricDefinition{
+			Name:        m.Name,
+			Type:        m.Type,
+			Source:      m.Source,
+			Description: m.Description,
+		})
+	}
+	return resp, nil
+}
+
+func (s controlServer) TriggerFault(_ context.Context, req *controlpb.TriggerFaultRequest) (*controlpb.TriggerFaultResponse, error) {
+	switch req.GetKind() {
+	case "restart":
+		log.Printf("Received force restart request over gRPC")
+		s.app.scheduleExit()
+		return &controlpb.TriggerFaultResponse{}, nil
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown fault kind %q, expected one of %v", req.GetKind(), faultKinds)
+	}
+}
+
+func (s controlServer) GetLedger(context.Context, *controlpb.GetLedgerRequest) (*controlpb.GetLedgerResponse, error) {
+	resp := &controlpb.GetLedgerResponse{}
+	for _, t := range s.app.Ledger() {
+		resp.Series = append(resp.Series, &controlpb.SeriesTotal{Tenant: t.Tenant, Path: t.Path, Total: t.Total})
+	}
+	return resp, nil
+}
+
+func workerProto(w WorkerInfo) *controlpb.Worker {
+	return &controlpb.Worker{
+		Tenant:          w.Tenant,
+		Path:            w.Path,
+		IncrementBy:     int64(w.IncrementBy),
+		IntervalSeconds: int64(w.IntervalSeconds),
+		StartedAt:       timestamppb.New(w.StartedAt),
+		Ticks:           w.Ticks,
+	}
+}
+
+// callTenant picks the tenant for a gRPC call like requestTenant does for
+// HTTP: the request field if set, otherwise the tenant metadata.
+func (a *App) callTenant(ctx context.Context, requestTenant string) string {
+	if requestTenant != "" {
+		return requestTenant
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get(a.tenantHeader()); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// grpcError maps limit errors to ResourceExhausted and any other to
+// InvalidArgument.
+func grpcError(err error) error {
+	var le *LimitError
+	if errors.As(err, &le) {
+		return status.Error(codes.ResourceExhausted, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+func checkPath(path string) error {
+	if err := validSeriesPath(path); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
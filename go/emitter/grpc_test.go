@@ -0,0 +1,42 @@
+package emitter
+
+import (
+	"context"
+	"testing"
+
+	"eriktestapp/controlpb"
+
+	"google.golang.org/grpc/metadata"
+)
+
+func TestGRPCTenantMetadata(t *testing.T) {
+	a := newTestApp(t, Config{})
+	client := grpcClient(t, a)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), defaultTenantHeader, "meta")
+
+	resp, err := client.Increment(ctx, &controlpb.IncrementRequest{Path: "/a", IncrementBy: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetTenant() != "meta" || resp.GetTotal() != 2 {
+		t.Errorf("response = %v, want tenant meta with total 2", resp)
+	}
+	if _, err := client.Increment(ctx, &controlpb.IncrementRequest{Path: "/a", IncrementBy: 3, Tenant: "field"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		tenant string
+		want   int64
+	}{{"meta", 2}, {"field", 3}, {"", 0}} {
+		if got := a.ledger.total(seriesKey{tc.tenant, "/a"}); got != tc.want {
+			t.Errorf("tenant %q total = %d, want %d", tc.tenant, got, tc.want)
+		}
+	}
+
+	if _, err := client.StartWorker(ctx, &controlpb.StartWorkerRequest{Path: "/w", IntervalSeconds: 3600}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.StopWorker(ctx, &controlpb.StopWorkerRequest{Path: "/w"}); err != nil {
+		t.Errorf("StopWorker with the same metadata: %v", err)
+	}
+}
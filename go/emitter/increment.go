@@ -0,0 +1,357 @@
+package emitter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type IncrementRequest struct {
+	IncrementBy              int `json:"incrementBy"`
+	IncrementByPeriodic      int `json:"incrementByPeriodic,omitempty"`
+	IncrementIntervalSeconds int `json:"incrementIntervalSeconds,omitempty"`
+	// Tenant overrides the tenant header. Empty means the default tenant.
+	Tenant string `json:"tenant,omitempty"`
+}
+
+type dummyHandler struct {
+	app *App
+}
+
+func (h *dummyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.app.handleIncrement(w, r)
+}
+
+type intervalWorker struct {
+	app             *App
+	tenant          *tenant
+	path            string
+	incBy           int
+	incIntervalSecs int
+	startedAt       time.Time
+	ticks           atomic.Int64
+	done            chan struct{}
+}
+
+func (w *intervalWorker) start() {
+	ticker := time.NewTicker(time.Duration(w.incIntervalSecs) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		log.Printf("Incrementing by %d for path %s", w.incBy, w.path)
+		if err := w.app.incrementPath(context.Background(), w.tenant, w.path, w.incBy); err != nil {
+			log.Printf("Incrementing path %s: %v", w.path, err)
+		}
+		w.callDownstream()
+		w.ticks.Add(1)
+		select {
+		case <-ticker.C:
+			continue
+		case <-w.done:
+			log.Printf("Stopping interval worker for path %s", w.path)
+			return
+		}
+	}
+}
+
+const defaultIntervalSecs = 10
+const defaultIncrementBy = 100
+
+// validSeriesPath checks path can be a series: the Prometheus counter needs
+// it to be a valid UTF-8 label value.
+func validSeriesPath(path string) error {
+	if !strings.HasPrefix(path, "/") {
+		return errors.New("path must start with /")
+	}
+	if !utf8.ValidString(path) {
+		return errors.New("path must be valid UTF-8")
+	}
+	return nil
+}
+
+// validate rejects negative increments, as a counter cannot go down.
+func (req IncrementRequest) validate() error {
+	if req.IncrementBy < 0 || req.IncrementByPeriodic < 0 {
+		return fmt.Errorf("increments must not be negative, got %d and %d", req.IncrementBy, req.IncrementByPeriodic)
+	}
+	return nil
+}
+
+// Increment applies req to path exactly as a POST to path on the "http"
+// listener would. It fails if path, req or req.Tenant is invalid, or with
+// a *LimitError if a tenant, series or worker limit is reached.
+func (a *App) Increment(path string, req IncrementRequest) error {
+	if err := validSeriesPath(path); err != nil {
+		return err
+	}
+	if err := req.validate(); err != nil {
+		return err
+	}
+	t, err := a.tenant(req.Tenant)
+	if err != nil {
+		return err
+	}
+	return a.applyIncrement(context.Background(), t, path, req)
+}
+
+// StartWorker starts an interval worker adding incBy to path for tenantID
+// every intervalSecs seconds, replacing any existing one. Over a tenant,
+// series or worker limit it fails with a *LimitError.
+func (a *App) StartWorker(tenantID, path string, incBy, intervalSecs int) error {
+	if err := validSeriesPath(path); err != nil {
+		return err
+	}
+	if incBy < 0 {
+		return fmt.Errorf("increment must not be negative, got %d", incBy)
+	}
+	t, err := a.tenant(tenantID)
+	if err != nil {
+		return err
+	}
+	_, err = a.setWorker(t, path, incBy, intervalSecs)
+	return err
+}
+
+// StopWorker stops the interval worker for tenantID and path and reports
+// whether there was one.
+func (a *App) StopWorker(tenantID, path string) bool {
+	return a.stopWorker(seriesKey{tenantID, path})
+}
+
+// incrementPath adds incBy to the tenant's OTLP counter and to the expected
+// totals for path, which the Prometheus counter is served from. Both happen
+// under the ledger lock so a reset sees exactly what the SDK has counted.
+// The OTLP counter also gets ctx's allowlisted baggage. It fails if path
+// would be a new series over the series limit.
+func (a *App) incrementPath(ctx context.Context, t *tenant, path string, incBy int) error {
+	attrs := attribute.NewSet(append([]attribute.KeyValue{attribute.String("path", path)}, a.baggageAttributes(ctx, SignalMetrics)...)...)
+	return a.ledger.update(seriesKey{t.id, path}, func(s *seriesState) {
+		// Update OTLP counter with path attribute
+		t.otlpPathIncrementSum.Add(ctx, int64(incBy), metric.WithAttributeSet(attrs))
+		s.count(attrs, int64(incBy))
+		s.total += int64(incBy)
+	})
+}
+
+// applyIncrement performs req against path: the one-off increment, then
+// starting or replacing the path's interval worker. Only the locks of the
+// path's shards are taken. ctx carries the request's baggage. The series
+// and worker limits are checked before anything is counted, so a request
+// turned away has no effect and can be retried.
+func (a *App) applyIncrement(ctx context.Context, t *tenant, path string, req IncrementRequest) error {
+	if a.logRequests {
+		log.Printf("Incrementing by %d for path %s%s", req.IncrementBy, path, formatAttributes(a.baggageAttributes(ctx, SignalLogs)))
+	}
+	key := seriesKey{t.id, path}
+	var err error
+	a.workers.with(key, func(m map[seriesKey]*intervalWorker) {
+		_, exists := m[key]
+		startWorker := exists || req.IncrementByPeriodic != 0 || req.IncrementIntervalSeconds != 0
+		if startWorker {
+			if err = a.limits.startWorker(exists); err != nil {
+				return
+			}
+		}
+		if err = a.incrementPath(ctx, t, path, req.IncrementBy); err != nil {
+			if startWorker && !exists {
+				a.limits.stopWorker()
+			}
+			return
+		}
+		if startWorker {
+			a.replaceWorker(m, t, path, max(req.IncrementByPeriodic, defaultIncrementBy), max(req.IncrementIntervalSeconds, defaultIntervalSecs))
+		}
+	})
+	return err
+}
+
+// setWorker starts an interval worker for path, replacing any existing
+// one, and returns it. The series is created up front so a worker never
+// runs for a series over the limit.
+func (a *App) setWorker(t *tenant, path string, incBy, intervalSecs int) (*intervalWorker, error) {
+	key := seriesKey{t.id, path}
+	var w *intervalWorker
+	var err error
+	a.workers.with(key, func(m map[seriesKey]*intervalWorker) {
+		_, exists := m[key]
+		if err = a.limits.startWorker(exists); err != nil {
+			return
+		}
+		if err = a.ledger.update(key, func(*seriesState) {}); err != nil {
+			if !exists {
+				a.limits.stopWorker()
+			}
+			return
+		}
+		w = a.replaceWorker(m, t, path, incBy, intervalSecs)
+	})
+	return w, err
+}
+
+// replaceWorker starts an interval worker for path in m, the workers of
+// path's shard, stopping any existing one. The shard's lock must be held
+// and the limiter must have allowed the start.
+func (a *App) replaceWorker(m map[seriesKey]*intervalWorker, t *tenant, path string, incBy, intervalSecs int) *intervalWorker {
+	key := seriesKey{t.id, path}
+	newWorker := &intervalWorker{
+		app:             a,
+		tenant:          t,
+		path:            path,
+		incBy:           incBy,
+		incIntervalSecs: intervalSecs,
+		startedAt:       time.Now(),
+		done:            make(chan struct{}),
+	}
+	if old, exists := m[key]; exists {
+		close(old.done)
+	}
+	m[key] = newWorker
+	go newWorker.start()
+	return newWorker
+}
+
+// stopWorker stops the interval worker for key and reports whether there
+// was one.
+func (a *App) stopWorker(key seriesKey) bool {
+	var exists bool
+	a.workers.with(key, func(m map[seriesKey]*intervalWorker) {
+		var worker *intervalWorker
+		if worker, exists = m[key]; exists {
+			close(worker.done)
+			delete(m, key)
+			a.limits.stopWorker()
+		}
+	})
+	return exists
+}
+
+// handleIncrement reads and parses the whole body before touching any
+// shared state, so a slow client holds no lock while it trickles its body.
+func (a *App) handleIncrement(w http.ResponseWriter, r *http.Request) {
+	if a.logRequests {
+		log.Printf("Received POST request to %s", r.URL.String())
+	}
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		if !writeBodyTooLargeError(w, err) {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		}
+		return
+	}
+	_ = r.Body.Close()
+
+	var req IncrementRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "Invalid JSON payload", http.StatusBadRequest)
+		return
+	}
+	if err := validSeriesPath(r.URL.Path); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := req.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// A matching workload profile delays the response and fails some
+	// requests, which are then not applied and create no tenant. Neither
+	// are requests whose client gives up waiting.
+	profile, simulated := a.workloadProfile(r.URL.Path)
+	if simulated {
+		code, err := profile.simulate(r.Context())
+		if err != nil {
+			w.WriteHeader(statusClientClosedRequest)
+			return
+		}
+		if code != 0 {
+			http.Error(w, http.StatusText(code), code)
+			return
+		}
+	}
+
+	t, err := a.tenant(a.requestTenant(r, req.Tenant))
+	if err != nil {
+		if !writeLimitError(w, err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	if err := a.callDownstream(r.Context(), t, r.URL.Path, req.IncrementBy, requestHops(r)); err != nil {
+		http.Error(w, "Downstream call failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	trace.SpanFromContext(r.Context()).SetAttributes(a.baggageAttributes(r.Context(), SignalSpans)...)
+	if err := a.applyIncrement(r.Context(), t, r.URL.Path, req); err != nil {
+		if !writeLimitError(w, err) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+	if simulated {
+		// Trailing whitespace keeps the echoed body valid JSON.
+		if pad := profile.responseSize() - len(body); pad > 0 {
+			_, _ = io.CopyN(w, spaces{}, int64(pad))
+		}
+	}
+}
+
+func (a *App) handleFlush(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Received flush request from %s", r.RemoteAddr)
+	if err := a.Flush(r.Context()); err != nil {
+		http.Error(w, "Flush failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Flushed\n"))
+}
+
+func (a *App) handleForceRestart(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Received force restart request from %s", r.RemoteAddr)
+
+	// Send response before shutting down
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Process shutting down for restart\n"))
+
+	// Flush response
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+
+	// Give a small delay to ensure response is sent
+	a.scheduleExit()
+}
+
+// scheduleExit exits the process shortly, giving in-flight responses time
+// to be sent.
+func (a *App) scheduleExit() {
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		log.Println("Forcing process restart by exiting...")
+		a.exit()
+	}()
+}
@@ -0,0 +1,109 @@
+package emitter
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// BenchmarkIncrementParallel measures increment throughput under
+// concurrent clients through the "http" listener's handler, so the numbers
+// are what one pod can take before the network stack gets involved. Use
+// -cpu to vary the number of clients.
+func BenchmarkIncrementParallel(b *testing.B) {
+	distinct := func(client int64) string { return "/bench-" + strconv.FormatInt(client, 10) }
+	for _, sc := range []struct {
+		name  string
+		setup func(b *testing.B, a *App, h http.Handler)
+		path  func(client int64) string
+	}{
+		{
+			name: "same-path",
+			path: func(int64) string { return "/bench" },
+		},
+		{
+			name: "distinct-paths",
+			path: distinct,
+		},
+		{
+			// A client that never finishes sending its body must not hold
+			// up anyone else.
+			name: "slow-client",
+			setup: func(b *testing.B, a *App, h http.Handler) {
+				pr, pw := io.Pipe()
+				req := httptest.NewRequest(http.MethodPost, "/bench-0", pr)
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					h.ServeHTTP(httptest.NewRecorder(), req)
+				}()
+				b.Cleanup(func() {
+					pw.Close()
+					<-done
+				})
+			},
+			path: distinct,
+		},
+		{
+			// Workers being replaced on other paths, as by POSTs setting
+			// incrementIntervalSeconds.
+			name: "worker-churn",
+			setup: func(b *testing.B, a *App, h http.Handler) {
+				stop := make(chan struct{})
+				done := make(chan struct{})
+				go func() {
+					defer close(done)
+					for i := 0; ; i++ {
+						select {
+						case <-stop:
+							return
+						default:
+						}
+						path := fmt.Sprintf("/worker-%d", i%100)
+						if err := a.StartWorker("", path, 1, 3600); err != nil {
+							b.Error(err)
+							return
+						}
+						a.StopWorker("", path)
+					}
+				}()
+				b.Cleanup(func() {
+					close(stop)
+					<-done
+				})
+			},
+			path: distinct,
+		},
+	} {
+		b.Run(sc.name, func(b *testing.B) {
+			// Workers log every tick.
+			out := log.Writer()
+			log.SetOutput(io.Discard)
+			b.Cleanup(func() { log.SetOutput(out) })
+			a := newTestApp(b, Config{Limits: LimitsConfig{MaxSeries: -1}})
+			h := a.Handler("http")
+			if sc.setup != nil {
+				sc.setup(b, a, h)
+			}
+			var clients atomic.Int64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				path := sc.path(clients.Add(1))
+				for pb.Next() {
+					w := postIncrement(h, path, `{"incrementBy":1}`)
+					if w.Code != http.StatusOK {
+						b.Errorf("POST %s: %d %s", path, w.Code, w.Body)
+						return
+					}
+				}
+			})
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "req/s")
+		})
+	}
+}
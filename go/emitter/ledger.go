@@ -0,0 +1,229 @@
+package emitter
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ledger records the total every path should have reached, i.e. the value
+// the backend is expected to hold for its series, and when each series was
+// created. The Prometheus counter is served straight from it, and OTLP
+// exports are aligned with it, so both agree on created timestamps. It is
+// sharded so increments of different series do not contend.
+type ledger struct {
+	series shardedMap[*seriesState]
+	// maxSeries caps how many series are created, if positive.
+	maxSeries int
+	count     atomic.Int64
+}
+
+type seriesState struct {
+	total   int64
+	created time.Time
+	// counts are what the OTLP counter has counted for each of the
+	// series' attribute sets, which differ by baggage.
+	counts map[attribute.Distinct]*attributeCount
+}
+
+// attributeCount is what the OTLP counter has counted for one attribute
+// set. The SDK cannot reset a counter, so exports subtract offset, what
+// had been counted at the last reset, instead.
+type attributeCount struct {
+	attrs   attribute.Set
+	counted int64
+	offset  int64
+}
+
+// count records that the OTLP counter added n to attrs.
+func (s *seriesState) count(attrs attribute.Set, n int64) {
+	c, ok := s.counts[attrs.Equivalent()]
+	if !ok {
+		if s.counts == nil {
+			s.counts = make(map[attribute.Distinct]*attributeCount)
+		}
+		c = &attributeCount{attrs: attrs}
+		s.counts[attrs.Equivalent()] = c
+	}
+	c.counted += n
+}
+
+// offset is what had been counted at the last reset for the exported
+// point with attrs. A view dropping attributes merges the sets that only
+// differ in them, so each set that reduces to attrs adds its offset.
+func (s *seriesState) offset(attrs attribute.Set) int64 {
+	var offset int64
+	for _, c := range s.counts {
+		if reduced, _ := c.attrs.Filter(func(kv attribute.KeyValue) bool { return attrs.HasValue(kv.Key) }); reduced.Equals(&attrs) {
+			offset += c.offset
+		}
+	}
+	return offset
+}
+
+type SeriesTotal struct {
+	Tenant  string    `json:"tenant,omitempty"`
+	Path    string    `json:"path"`
+	Total   int64     `json:"total"`
+	Created time.Time `json:"created,omitzero"`
+}
+
+// update runs fn on the series for key under its shard's lock, creating
+// the series first if needed and maxSeries allows.
+func (l *ledger) update(key seriesKey, fn func(*seriesState)) error {
+	var err error
+	l.series.with(key, func(m map[seriesKey]*seriesState) {
+		s, ok := m[key]
+		if !ok {
+			if n := l.count.Add(1); l.maxSeries > 0 && n > int64(l.maxSeries) {
+				l.count.Add(-1)
+				err = &LimitError{Limit: fmt.Sprintf("series count (%d)", l.maxSeries), RetryAfter: capRetryAfter}
+				return
+			}
+			s = &seriesState{created: time.Now()}
+			m[key] = s
+		}
+		fn(s)
+	})
+	return err
+}
+
+// modify runs fn on an existing series and returns its new total, or false
+// if there is no such series.
+func (l *ledger) modify(key seriesKey, fn func(*seriesState)) (SeriesTotal, bool) {
+	var out SeriesTotal
+	var ok bool
+	l.series.with(key, func(m map[seriesKey]*seriesState) {
+		var s *seriesState
+		if s, ok = m[key]; ok {
+			fn(s)
+			out = SeriesTotal{Tenant: key.tenant, Path: key.path, Total: s.total, Created: s.created}
+		}
+	})
+	return out, ok
+}
+
+// state returns the total and created time of the series for key.
+func (l *ledger) state(key seriesKey) (seriesState, bool) {
+	var out seriesState
+	var ok bool
+	l.series.with(key, func(m map[seriesKey]*seriesState) {
+		var s *seriesState
+		if s, ok = m[key]; ok {
+			out = seriesState{total: s.total, created: s.created}
+		}
+	})
+	return out, ok
+}
+
+// start returns the created time of the series for key and the offset of
+// its exported point with attrs.
+func (l *ledger) start(key seriesKey, attrs attribute.Set) (time.Time, int64, bool) {
+	var created time.Time
+	var offset int64
+	var ok bool
+	l.series.with(key, func(m map[seriesKey]*seriesState) {
+		var s *seriesState
+		if s, ok = m[key]; ok {
+			created, offset = s.created, s.offset(attrs)
+		}
+	})
+	return created, offset, ok
+}
+
+func (l *ledger) total(key seriesKey) int64 {
+	s, _ := l.state(key)
+	return s.total
+}
+
+// snapshot returns the expected totals sorted by tenant and path.
+func (l *ledger) snapshot() []SeriesTotal {
+	var out []SeriesTotal
+	l.series.all(func(m map[seriesKey]*seriesState) {
+		for key, s := range m {
+			out = append(out, SeriesTotal{Tenant: key.tenant, Path: key.path, Total: s.total, Created: s.created})
+		}
+	})
+	if out == nil {
+		out = []SeriesTotal{}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Tenant != out[j].Tenant {
+			return out[i].Tenant < out[j].Tenant
+		}
+		return out[i].Path < out[j].Path
+	})
+	return out
+}
+
+// Ledger returns the total every series is expected to have reached.
+func (a *App) Ledger() []SeriesTotal {
+	return a.ledger.snapshot()
+}
+
+// ResetSeries resets a series to zero with a new created timestamp, as a
+// restarted process would, and reports whether there was such a series.
+func (a *App) ResetSeries(tenantID, path string) (SeriesTotal, bool) {
+	return a.ledger.modify(seriesKey{tenantID, path}, func(s *seriesState) {
+		for _, c := range s.counts {
+			c.offset = c.counted
+		}
+		s.total = 0
+		s.created = time.Now()
+	})
+}
+
+// SetSeriesCreated overrides a series' created timestamp and reports
+// whether there was such a series.
+func (a *App) SetSeriesCreated(tenantID, path string, created time.Time) (SeriesTotal, bool) {
+	return a.ledger.modify(seriesKey{tenantID, path}, func(s *seriesState) {
+		s.created = created
+	})
+}
+
+// ledgerCollector exposes one tenant's ledger as the path counter, with
+// each series' created timestamp.
+type ledgerCollector struct {
+	ledger *ledger
+	tenant string
+	desc   *prometheus.Desc
+}
+
+func newLedgerCollector(l *ledger, tenant string) *ledgerCollector {
+	return &ledgerCollector{
+		ledger: l,
+		tenant: tenant,
+		desc:   prometheus.NewDesc(promCounterName, "Running sum of incrementBy values by path", []string{"path"}, nil),
+	}
+}
+
+func (c *ledgerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *ledgerCollector) Collect(ch chan<- prometheus.Metric) {
+	var metrics []prometheus.Metric
+	c.ledger.series.all(func(m map[seriesKey]*seriesState) {
+		for key, s := range m {
+			if key.tenant == c.tenant {
+				m, err := prometheus.NewConstMetricWithCreatedTimestamp(
+					c.desc, prometheus.CounterValue, float64(s.total), s.created, key.path)
+				if err != nil {
+					// Paths are validated on the way in; skip rather than
+					// fail the whole scrape if one got through anyway.
+					log.Printf("Skipping series %q from /metrics: %v", key.path, err)
+					continue
+				}
+				metrics = append(metrics, m)
+			}
+		}
+	})
+	for _, m := range metrics {
+		ch <- m
+	}
+}
@@ -0,0 +1,124 @@
+package emitter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// pathCounter gathers the Prometheus path counter, keyed by path.
+func pathCounter(t *testing.T, a *App) map[string]*dto.Metric {
+	t.Helper()
+	mfs, err := a.Gatherer().Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[string]*dto.Metric)
+	for _, mf := range mfs {
+		if mf.GetName() != promCounterName {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "path" {
+					out[l.GetValue()] = m
+				}
+			}
+		}
+	}
+	return out
+}
+
+func TestLedgerCollector(t *testing.T) {
+	a := newTestApp(t, Config{})
+	before := time.Now()
+	for _, inc := range []int{2, 3} {
+		if err := a.Increment("/a", IncrementRequest{IncrementBy: inc}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := pathCounter(t, a)["/a"]
+	if m == nil {
+		t.Fatal("no series for /a")
+	}
+	if got := m.GetCounter().GetValue(); got != 5 {
+		t.Errorf("value = %g, want 5", got)
+	}
+	if created := m.GetCounter().GetCreatedTimestamp().AsTime(); created.Before(before.Truncate(time.Second)) {
+		t.Errorf("created = %v, before the first increment at %v", created, before)
+	}
+
+	reset, ok := a.ResetSeries("", "/a")
+	if !ok || reset.Total != 0 {
+		t.Fatalf("ResetSeries = %+v, %t", reset, ok)
+	}
+	if got := pathCounter(t, a)["/a"].GetCounter().GetValue(); got != 0 {
+		t.Errorf("value after reset = %g, want 0", got)
+	}
+}
+
+func TestIncrementRejectsInvalid(t *testing.T) {
+	a := newTestApp(t, Config{})
+	h := a.Handler("http")
+	for _, tc := range []struct {
+		path, body string
+	}{
+		{"/%ff", `{"incrementBy":1}`},
+		{"/a", `{"incrementBy":-1}`},
+		{"/a", `{"incrementBy":1,"incrementByPeriodic":-5}`},
+	} {
+		if w := postIncrement(h, tc.path, tc.body); w.Code != http.StatusBadRequest {
+			t.Errorf("POST %s %s: status %d, want 400", tc.path, tc.body, w.Code)
+		}
+	}
+	if err := a.Increment("/\xff", IncrementRequest{IncrementBy: 1}); err == nil {
+		t.Error("Increment accepted a path that is not UTF-8")
+	}
+	if err := a.StartWorker("", "/a", -1, 10); err == nil {
+		t.Error("StartWorker accepted a negative increment")
+	}
+	if len(a.Ledger()) != 0 {
+		t.Errorf("rejected increments were applied: %+v", a.Ledger())
+	}
+
+	w := httptest.NewRecorder()
+	a.Handler("metrics").ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("GET /metrics: status %d: %s", w.Code, w.Body)
+	}
+}
+
+// TestLedgerCollectorSkipsInvalid checks a series the collector cannot
+// expose is skipped instead of failing or crashing the scrape.
+func TestLedgerCollectorSkipsInvalid(t *testing.T) {
+	a := newTestApp(t, Config{})
+	if err := a.Increment("/ok", IncrementRequest{IncrementBy: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.ledger.update(seriesKey{"", "/\xff"}, func(s *seriesState) { s.total = 1 }); err != nil {
+		t.Fatal(err)
+	}
+	series := pathCounter(t, a)
+	if len(series) != 1 || series["/ok"] == nil {
+		t.Errorf("series = %v, want only /ok", series)
+	}
+}
+
+func TestLedgerSeriesCap(t *testing.T) {
+	l := ledger{maxSeries: 2}
+	for _, p := range []string{"/a", "/b", "/a"} {
+		if err := l.update(seriesKey{"", p}, func(*seriesState) {}); err != nil {
+			t.Fatalf("update %s: %v", p, err)
+		}
+	}
+	err := l.update(seriesKey{"t", "/a"}, func(*seriesState) {})
+	if _, ok := err.(*LimitError); !ok {
+		t.Fatalf("update over the cap = %v, want a *LimitError", err)
+	}
+	if got := len(l.snapshot()); got != 2 {
+		t.Errorf("%d series, want 2", got)
+	}
+}
@@ -0,0 +1,407 @@
+package emitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/peer"
+)
+
+const (
+	defaultMaxBodyBytes   = 1 << 20
+	defaultMaxReplayBytes = 64 << 20
+	defaultMaxWorkers     = 10000
+	defaultMaxSeries      = 100000
+	defaultMaxTenants     = 100
+	defaultMaxTargets     = 1000
+	// capRetryAfter is the Retry-After sent when a worker, series, tenant
+	// or body size cap is hit. Stopping workers frees the first; series and
+	// tenants last until a restart, and a body stays too large until it is
+	// split.
+	capRetryAfter = time.Minute
+	// maxClientBuckets bounds how many clients' buckets are kept; idle ones
+	// are dropped beyond it.
+	maxClientBuckets = 4096
+)
+
+// LimitsConfig keeps a misconfigured load test from taking the pod down.
+// Rates are per second and unlimited when zero; a burst defaults to the
+// rate rounded up. Requests over a rate or cap get 429 with Retry-After,
+// and bodies over their cap get 413. Increments are limited however they
+// are sent: to a series path, to /api/increment or over gRPC. Replays
+// through the API count as increments too.
+type LimitsConfig struct {
+	// MaxBodyBytes caps the body of an increment, of any other POST to the
+	// API but a replay, and of any gRPC control message. Defaults to 1 MiB.
+	MaxBodyBytes int64 `json:"maxBodyBytes,omitempty"`
+	// MaxReplayBytes caps the body of a replay through the API. Defaults
+	// to 64 MiB.
+	MaxReplayBytes int64 `json:"maxReplayBytes,omitempty"`
+	// RequestsPerSecond limits increments across all clients.
+	RequestsPerSecond float64 `json:"requestsPerSecond,omitempty"`
+	Burst             int     `json:"burst,omitempty"`
+	// ClientRequestsPerSecond limits increments from each client address.
+	ClientRequestsPerSecond float64 `json:"clientRequestsPerSecond,omitempty"`
+	ClientBurst             int     `json:"clientBurst,omitempty"`
+	// WorkerStartsPerSecond limits starting and replacing interval
+	// workers, however they are started.
+	WorkerStartsPerSecond float64 `json:"workerStartsPerSecond,omitempty"`
+	WorkerStartsBurst     int     `json:"workerStartsBurst,omitempty"`
+	// MaxWorkers defaults to 10000 and MaxSeries, the increment series
+	// tracked across tenants, to 100000. -1 removes the cap.
+	MaxWorkers int `json:"maxWorkers,omitempty"`
+	MaxSeries  int `json:"maxSeries,omitempty"`
+	// MaxTenants caps the tenants created from request headers and
+	// payloads, each with its own registry, exporter and MeterProvider.
+	// Tenants in tenants.overrides are always accepted. Defaults to 100;
+	// -1 removes the cap.
+	MaxTenants int `json:"maxTenants,omitempty"`
+	// MaxTargets caps the virtual targets, each with its own registry,
+	// however the count is set. Defaults to 1000; -1 removes the cap.
+	MaxTargets int `json:"maxTargets,omitempty"`
+}
+
+func (c LimitsConfig) validate() error {
+	if c.MaxBodyBytes < 0 || c.MaxReplayBytes < 0 {
+		return fmt.Errorf("max body and replay bytes must not be negative, got %d and %d", c.MaxBodyBytes, c.MaxReplayBytes)
+	}
+	for _, r := range []struct {
+		name  string
+		rate  float64
+		burst int
+	}{
+		{"requests", c.RequestsPerSecond, c.Burst},
+		{"client requests", c.ClientRequestsPerSecond, c.ClientBurst},
+		{"worker starts", c.WorkerStartsPerSecond, c.WorkerStartsBurst},
+	} {
+		if r.rate < 0 || r.burst < 0 {
+			return fmt.Errorf("%s rate and burst must not be negative, got %g and %d", r.name, r.rate, r.burst)
+		}
+	}
+	if c.MaxWorkers < -1 || c.MaxSeries < -1 || c.MaxTenants < -1 || c.MaxTargets < -1 {
+		return fmt.Errorf("max workers, series, tenants and targets must be -1 or more, got %d, %d, %d and %d", c.MaxWorkers, c.MaxSeries, c.MaxTenants, c.MaxTargets)
+	}
+	return nil
+}
+
+// LimitError is returned when a request is over a rate or cap.
+type LimitError struct {
+	// Limit names what was exceeded.
+	Limit string
+	// RetryAfter is how long to wait before trying again.
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s limit exceeded, retry after %s", e.Limit, e.RetryAfter.Round(time.Millisecond))
+}
+
+// tokenBucket allows rate events per second with bursts of up to burst. A
+// nil bucket allows everything.
+type tokenBucket struct {
+	rate  float64
+	burst float64
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	b := float64(burst)
+	if burst <= 0 {
+		b = math.Ceil(rate)
+	}
+	return &tokenBucket{rate: rate, burst: b, tokens: b, last: time.Now()}
+}
+
+// refill adds the tokens earned since the last call. b.mu must be held.
+// A now before the last call, as when the bucket was created after now
+// was taken, earns nothing.
+func (b *tokenBucket) refill(now time.Time) {
+	if !now.After(b.last) {
+		return
+	}
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+}
+
+// take takes a token, or returns how long until one is available.
+func (b *tokenBucket) take(now time.Time) (time.Duration, bool) {
+	if b == nil {
+		return 0, true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill(now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+	return time.Duration((1 - b.tokens) / b.rate * float64(time.Second)), false
+}
+
+// clientBuckets holds a token bucket per client address.
+type clientBuckets struct {
+	rate  float64
+	burst int
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func (c *clientBuckets) take(client string, now time.Time) (time.Duration, bool) {
+	if c.rate <= 0 {
+		return 0, true
+	}
+	c.mu.Lock()
+	b, ok := c.buckets[client]
+	if !ok {
+		if len(c.buckets) >= maxClientBuckets {
+			c.pruneLocked(now)
+		}
+		b = newTokenBucket(c.rate, c.burst)
+		c.buckets[client] = b
+	}
+	c.mu.Unlock()
+	return b.take(now)
+}
+
+// pruneLocked drops the buckets that have refilled, which are the same as
+// new ones. c.mu must be held.
+func (c *clientBuckets) pruneLocked(now time.Time) {
+	for client, b := range c.buckets {
+		b.mu.Lock()
+		b.refill(now)
+		full := b.tokens >= b.burst
+		b.mu.Unlock()
+		if full {
+			delete(c.buckets, client)
+		}
+	}
+}
+
+// limiter enforces a LimitsConfig.
+type limiter struct {
+	maxBodyBytes   int64
+	maxReplayBytes int64
+	maxWorkers     int
+	maxTenants     int
+	maxTargets     int
+	requests       *tokenBucket
+	clients        clientBuckets
+	workerStarts   *tokenBucket
+	// workers counts the running interval workers.
+	workers atomic.Int64
+}
+
+func newLimiter(cfg LimitsConfig) *limiter {
+	l := &limiter{
+		maxBodyBytes:   cfg.MaxBodyBytes,
+		maxReplayBytes: cfg.MaxReplayBytes,
+		maxWorkers:     cfg.MaxWorkers,
+		maxTenants:     cfg.MaxTenants,
+		maxTargets:     maxTargets(cfg),
+		requests:       newTokenBucket(cfg.RequestsPerSecond, cfg.Burst),
+		clients: clientBuckets{
+			rate:    cfg.ClientRequestsPerSecond,
+			burst:   cfg.ClientBurst,
+			buckets: make(map[string]*tokenBucket),
+		},
+		workerStarts: newTokenBucket(cfg.WorkerStartsPerSecond, cfg.WorkerStartsBurst),
+	}
+	if l.maxBodyBytes == 0 {
+		l.maxBodyBytes = defaultMaxBodyBytes
+	}
+	if l.maxReplayBytes == 0 {
+		l.maxReplayBytes = defaultMaxReplayBytes
+	}
+	if l.maxWorkers == 0 {
+		l.maxWorkers = defaultMaxWorkers
+	}
+	if l.maxTenants == 0 {
+		l.maxTenants = defaultMaxTenants
+	}
+	return l
+}
+
+// maxSeries returns the series cap of cfg, or 0 for none.
+func maxSeries(cfg LimitsConfig) int {
+	switch cfg.MaxSeries {
+	case 0:
+		return defaultMaxSeries
+	case -1:
+		return 0
+	}
+	return cfg.MaxSeries
+}
+
+// maxTargets returns the target cap of cfg, or 0 for none.
+func maxTargets(cfg LimitsConfig) int {
+	switch cfg.MaxTargets {
+	case 0:
+		return defaultMaxTargets
+	case -1:
+		return 0
+	}
+	return cfg.MaxTargets
+}
+
+// allowRequest takes a token for an increment from client.
+func (l *limiter) allowRequest(client string) error {
+	now := time.Now()
+	if wait, ok := l.clients.take(client, now); !ok {
+		return &LimitError{Limit: "client request rate", RetryAfter: wait}
+	}
+	if wait, ok := l.requests.take(now); !ok {
+		return &LimitError{Limit: "request rate", RetryAfter: wait}
+	}
+	return nil
+}
+
+// startWorker accounts for starting a worker, replacing an existing one
+// if replacing is true. Call stopWorker when a new one stops.
+func (l *limiter) startWorker(replacing bool) error {
+	if wait, ok := l.workerStarts.take(time.Now()); !ok {
+		return &LimitError{Limit: "worker start rate", RetryAfter: wait}
+	}
+	if replacing {
+		return nil
+	}
+	if n := l.workers.Add(1); l.maxWorkers > 0 && n > int64(l.maxWorkers) {
+		l.workers.Add(-1)
+		return &LimitError{Limit: fmt.Sprintf("worker count (%d)", l.maxWorkers), RetryAfter: capRetryAfter}
+	}
+	return nil
+}
+
+func (l *limiter) stopWorker() {
+	l.workers.Add(-1)
+}
+
+// allowTenant reports whether a tenant can be added to n existing ones.
+func (l *limiter) allowTenant(n int) error {
+	if l.maxTenants > 0 && n >= l.maxTenants {
+		return &LimitError{Limit: fmt.Sprintf("tenant count (%d)", l.maxTenants), RetryAfter: capRetryAfter}
+	}
+	return nil
+}
+
+// peerAddress identifies the client of a gRPC call for rate limiting.
+func peerAddress(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// clientAddress identifies the client of r for rate limiting.
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// limitIncrements wraps the increment route in the request limits.
+func (a *App) limitIncrements(next http.Handler) http.Handler {
+	return a.limitRequests(a.limits.maxBodyBytes, next)
+}
+
+// limitAPI wraps the API route so /api/increment gets the same limits as
+// the increment route, and replays the same rates with their own body cap.
+// Any other POST has its body capped like an increment's.
+func (a *App) limitAPI(next http.Handler) http.Handler {
+	increments := a.limitRequests(a.limits.maxBodyBytes, next)
+	replays := a.limitRequests(a.limits.maxReplayBytes, next)
+	others := capBody(a.limits.maxBodyBytes, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method != http.MethodPost:
+			next.ServeHTTP(w, r)
+		case r.URL.Path == "/api/increment":
+			increments.ServeHTTP(w, r)
+		case r.URL.Path == "/api/replay", r.URL.Path == "/api/control/replay":
+			replays.ServeHTTP(w, r)
+		default:
+			others.ServeHTTP(w, r)
+		}
+	})
+}
+
+// limitRequests turns away requests over the rate limits before their body
+// is read, and caps bodies at maxBytes. Requests forwarded by another
+// replica were already counted there.
+func (a *App) limitRequests(maxBytes int64, next http.Handler) http.Handler {
+	capped := capBody(maxBytes, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.forwardedByPeer(r) {
+			if err := a.limits.allowRequest(clientAddress(r)); err != nil {
+				writeLimitError(w, err)
+				return
+			}
+		}
+		capped.ServeHTTP(w, r)
+	})
+}
+
+// capBody turns away bodies declared larger than maxBytes and stops
+// reading undeclared ones there.
+func capBody(maxBytes int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ContentLength > maxBytes {
+			writeBodyTooLarge(w, maxBytes)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeLimitError answers with 429 and Retry-After if err is a LimitError,
+// reporting whether it was.
+func writeLimitError(w http.ResponseWriter, err error) bool {
+	var le *LimitError
+	if !errors.As(err, &le) {
+		return false
+	}
+	secs := max(int(math.Ceil(le.RetryAfter.Seconds())), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	http.Error(w, le.Error(), http.StatusTooManyRequests)
+	return true
+}
+
+// writeBodyTooLargeError answers with 413 if err is from reading past a body
+// limit, reporting whether it was.
+func writeBodyTooLargeError(w http.ResponseWriter, err error) bool {
+	var mbe *http.MaxBytesError
+	if !errors.As(err, &mbe) {
+		return false
+	}
+	writeBodyTooLarge(w, mbe.Limit)
+	return true
+}
+
+// writeBodyTooLarge answers with 413 and, as for the other caps,
+// capRetryAfter.
+func writeBodyTooLarge(w http.ResponseWriter, limit int64) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(capRetryAfter.Seconds())))
+	http.Error(w, fmt.Sprintf("Request body is larger than %d bytes", limit), http.StatusRequestEntityTooLarge)
+}
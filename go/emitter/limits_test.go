@@ -0,0 +1,206 @@
+package emitter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"eriktestapp/controlpb"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestTokenBucket(t *testing.T) {
+	if _, ok := newTokenBucket(0, 5).take(time.Now()); !ok {
+		t.Error("unlimited bucket refused")
+	}
+	b := newTokenBucket(2, 3)
+	now := b.last
+	for i := range 3 {
+		if _, ok := b.take(now); !ok {
+			t.Fatalf("take %d within the burst refused", i)
+		}
+	}
+	wait, ok := b.take(now)
+	if ok || wait != 500*time.Millisecond {
+		t.Fatalf("take after the burst = %s, %t, want 500ms, false", wait, ok)
+	}
+	if _, ok := b.take(now.Add(wait)); !ok {
+		t.Error("take after waiting refused")
+	}
+	// A time before the last take earns nothing.
+	if _, ok := b.take(now.Add(-time.Hour)); ok {
+		t.Error("take with an earlier time allowed")
+	}
+}
+
+func TestClientBuckets(t *testing.T) {
+	l := newLimiter(LimitsConfig{ClientRequestsPerSecond: 1})
+	if err := l.allowRequest("a"); err != nil {
+		t.Fatal(err)
+	}
+	var le *LimitError
+	if err := l.allowRequest("a"); !errors.As(err, &le) || le.Limit != "client request rate" {
+		t.Errorf("second request from a: %v, want a client rate limit", err)
+	}
+	if err := l.allowRequest("b"); err != nil {
+		t.Errorf("first request from b: %v", err)
+	}
+}
+
+func TestLimiterWorkers(t *testing.T) {
+	l := newLimiter(LimitsConfig{MaxWorkers: 2})
+	for range 2 {
+		if err := l.startWorker(false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := l.startWorker(true); err != nil {
+		t.Errorf("replacing at the cap: %v", err)
+	}
+	var le *LimitError
+	if err := l.startWorker(false); !errors.As(err, &le) || le.RetryAfter != capRetryAfter {
+		t.Errorf("starting over the cap: %v", err)
+	}
+	l.stopWorker()
+	if err := l.startWorker(false); err != nil {
+		t.Errorf("starting after a stop: %v", err)
+	}
+}
+
+func TestLimitsConfigValidate(t *testing.T) {
+	for _, c := range []LimitsConfig{
+		{MaxBodyBytes: -1},
+		{RequestsPerSecond: -1},
+		{ClientBurst: -1},
+		{MaxSeries: -2},
+	} {
+		if c.validate() == nil {
+			t.Errorf("%+v is valid", c)
+		}
+	}
+	if err := (LimitsConfig{MaxWorkers: -1, MaxTenants: -1}).validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLimitIncrements(t *testing.T) {
+	a := newTestApp(t, Config{Limits: LimitsConfig{MaxBodyBytes: 32, RequestsPerSecond: 1, Burst: 2}})
+	h := a.Handler("http")
+	if w := postIncrement(h, "/a", `{"incrementBy":1,"tenant":"`+strings.Repeat("x", 32)+`"}`); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large body: %d, want 413", w.Code)
+	}
+	if w := postIncrement(h, "/a", `{"incrementBy":1}`); w.Code != http.StatusOK {
+		t.Errorf("within the rate: %d %s", w.Code, w.Body)
+	}
+	w := postIncrement(h, "/a", `{"incrementBy":1}`)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("over the rate: %d with Retry-After %q, want 429 with 1", w.Code, w.Header().Get("Retry-After"))
+	}
+	if got := a.ledger.total(seriesKey{"", "/a"}); got != 1 {
+		t.Errorf("total = %d, want 1", got)
+	}
+}
+
+func TestLimitAPI(t *testing.T) {
+	a := newTestApp(t, Config{Limits: LimitsConfig{MaxBodyBytes: 32, MaxReplayBytes: 64, RequestsPerSecond: 1, Burst: 3}})
+	h := a.Handler("metrics")
+	for _, tc := range []struct {
+		path, body string
+		want       int
+	}{
+		{"/api/increment", `{"path":"/a","incrementBy":1,"tenant":"` + strings.Repeat("x", 32) + `"}`, http.StatusRequestEntityTooLarge},
+		{"/api/replay?format=json", strings.Repeat(" ", 65), http.StatusRequestEntityTooLarge},
+		{"/api/workers", `{"path":"/a","tenant":"` + strings.Repeat("x", 32) + `"}`, http.StatusRequestEntityTooLarge},
+		{"/api/variants", `{"name":"v","type":"gauge","help":"` + strings.Repeat("x", 32) + `"}`, http.StatusRequestEntityTooLarge},
+		{"/api/increment", `{"path":"/a","incrementBy":1}`, http.StatusOK},
+		{"/api/increment", `{"path":"/a","incrementBy":1}`, http.StatusTooManyRequests},
+		{"/api/replay?format=json", "", http.StatusTooManyRequests},
+	} {
+		w := postIncrement(h, tc.path, tc.body)
+		if w.Code != tc.want {
+			t.Errorf("POST %s: %d %s, want %d", tc.path, w.Code, w.Body, tc.want)
+		}
+		if w.Code >= 400 && w.Header().Get("Retry-After") == "" {
+			t.Errorf("POST %s: %d without Retry-After", tc.path, w.Code)
+		}
+	}
+	// Reads are not rate limited.
+	req := httptest.NewRequest(http.MethodGet, "/api/series", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("GET /api/series: %d", w.Code)
+	}
+}
+
+func TestLimitGRPCIncrement(t *testing.T) {
+	a := newTestApp(t, Config{Limits: LimitsConfig{RequestsPerSecond: 1}})
+	client := grpcClient(t, a)
+	ctx := context.Background()
+	if _, err := client.Increment(ctx, &controlpb.IncrementRequest{Path: "/a", IncrementBy: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Increment(ctx, &controlpb.IncrementRequest{Path: "/a", IncrementBy: 1}); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("second Increment: %v, want ResourceExhausted", err)
+	}
+}
+
+func TestForwardedHeaderTrust(t *testing.T) {
+	a := newTestApp(t, Config{Limits: LimitsConfig{RequestsPerSecond: 1}})
+	h := a.limitIncrements(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	post := func(remote string) int {
+		req := httptest.NewRequest(http.MethodPost, "/a", nil)
+		req.RemoteAddr = remote
+		req.Header.Set(forwardedHeader, "10.0.0.1")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+	post("10.0.0.1:1000")
+	if code := post("10.0.0.1:1000"); code != http.StatusTooManyRequests {
+		t.Errorf("forwarded outside cluster mode: %d, want 429", code)
+	}
+
+	a.cluster = testCluster("10.0.0.1", "10.0.0.2")
+	if code := post("10.0.0.2:1000"); code != http.StatusOK {
+		t.Errorf("forwarded by a member: %d, want 200", code)
+	}
+	if code := post("10.0.0.9:1000"); code != http.StatusTooManyRequests {
+		t.Errorf("forwarded by a stranger: %d, want 429", code)
+	}
+}
+
+// TestLimitsBeforeIncrement checks a request over a cap changes nothing,
+// so retrying it does not count twice.
+func TestLimitsBeforeIncrement(t *testing.T) {
+	a := newTestApp(t, Config{Limits: LimitsConfig{MaxWorkers: 1, MaxSeries: 2}})
+	h := a.Handler("http")
+	if w := postIncrement(h, "/a", `{"incrementBy":1,"incrementIntervalSeconds":3600}`); w.Code != http.StatusOK {
+		t.Fatalf("first worker: %d %s", w.Code, w.Body)
+	}
+	for range 2 {
+		if w := postIncrement(h, "/b", `{"incrementBy":5,"incrementIntervalSeconds":3600}`); w.Code != http.StatusTooManyRequests {
+			t.Fatalf("worker over the cap: %d %s", w.Code, w.Body)
+		}
+	}
+	if _, ok := a.ledger.state(seriesKey{"", "/b"}); ok {
+		t.Error("series /b created by a request over the worker cap")
+	}
+
+	if w := postIncrement(h, "/c", `{"incrementBy":1}`); w.Code != http.StatusOK {
+		t.Fatalf("second series: %d %s", w.Code, w.Body)
+	}
+	a.StopWorker("", "/a")
+	if w := postIncrement(h, "/d", `{"incrementBy":1,"incrementIntervalSeconds":3600}`); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("series over the cap: %d %s", w.Code, w.Body)
+	}
+	if n := a.limits.workers.Load(); n != 0 {
+		t.Errorf("%d workers counted after a request over the series cap", n)
+	}
+}
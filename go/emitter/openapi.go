@@ -0,0 +1,17 @@
+package emitter
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec describes the HTTP control API. Keep it, and the client
+// package written against it, in step with api.go.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+func handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openAPISpec)
+}
@@ -0,0 +1,100 @@
+package emitter
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// undocumentedRoutes are not part of the control API the spec describes.
+var undocumentedRoutes = map[string]bool{
+	RouteAPI:       true, // its endpoints are checked one by one
+	RouteDashboard: true,
+	RouteGRPC:      true,
+	RouteOpenAPI:   true,
+}
+
+// specPaths returns the methods of every path in openapi.json.
+func specPaths(t *testing.T) map[string][]string {
+	t.Helper()
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[string][]string)
+	for path, ops := range spec.Paths {
+		for method := range ops {
+			out[path] = append(out[path], strings.ToUpper(method))
+		}
+	}
+	return out
+}
+
+// TestOpenAPICoversRoutes checks every mounted pattern is in the spec.
+func TestOpenAPICoversRoutes(t *testing.T) {
+	spec := specPaths(t)
+	var patterns []string
+	for name, ps := range routePatterns {
+		if !undocumentedRoutes[name] {
+			patterns = append(patterns, ps...)
+		}
+	}
+	for p := range (&App{}).apiRoutes() {
+		patterns = append(patterns, p)
+	}
+	for _, p := range patterns {
+		method, path, ok := strings.Cut(p, " ")
+		if !ok {
+			method, path = "", p
+		}
+		if path == "/" {
+			path = "/{path}"
+		}
+		var found bool
+		for sp, methods := range spec {
+			if sp == path || strings.HasSuffix(path, "/") && strings.HasPrefix(sp, path) {
+				found = method == "" || strings.Contains(strings.Join(methods, " "), method)
+				if found {
+					break
+				}
+			}
+		}
+		if !found {
+			t.Errorf("pattern %q is not in openapi.json", p)
+		}
+	}
+}
+
+// TestOpenAPIPathsServed checks every path in the spec reaches a handler
+// with the documented method.
+func TestOpenAPIPathsServed(t *testing.T) {
+	lc := ListenerConfig{Name: "all"}
+	handlers := make(map[string]http.Handler)
+	for name := range routePatterns {
+		lc.Routes = append(lc.Routes, name)
+		handlers[name] = http.NotFoundHandler()
+	}
+	mux := newMux(lc, handlers)
+	api := http.NewServeMux()
+	for p := range (&App{}).apiRoutes() {
+		api.Handle(p, http.NotFoundHandler())
+	}
+	param := regexp.MustCompile(`\{[^}]+\}`)
+	for path, methods := range specPaths(t) {
+		for _, method := range methods {
+			req := httptest.NewRequest(method, param.ReplaceAllString(path, "x"), nil)
+			_, pattern := mux.Handler(req)
+			if strings.HasPrefix(path, "/api/") {
+				_, pattern = api.Handler(req)
+			}
+			if pattern == "" || pattern == "/" && path != "/{path}" {
+				t.Errorf("%s %s is not served, it matches %q", method, path, pattern)
+			}
+		}
+	}
+}
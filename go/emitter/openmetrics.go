@@ -0,0 +1,129 @@
+package emitter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+)
+
+// metricsHandler serves g with promhttp, except that OpenMetrics scrapes
+// are encoded here: promhttp writes no # UNIT lines and expfmt cannot
+// express infos, statesets or gauge histograms, which the variants of
+// registry may be.
+func (a *App) metricsHandler(g prometheus.Gatherer, registry string) http.Handler {
+	prom := promhttp.HandlerFor(g, a.promHandlerOpts())
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format := expfmt.NegotiateIncludingOpenMetrics(r.Header)
+		if !a.openMetrics || format.FormatType() != expfmt.TypeOpenMetrics {
+			prom.ServeHTTP(w, r)
+			return
+		}
+		mfs, err := g.Gather()
+		if err != nil {
+			http.Error(w, "Error gathering metrics: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		opts := []expfmt.EncoderOption{expfmt.WithUnit()}
+		if a.createdSamples {
+			opts = append(opts, expfmt.WithCreatedLines())
+		}
+		variants := a.registryVariants(registry)
+
+		// Encode everything before writing anything, as promhttp does, so
+		// an encoding error is not appended to a partial exposition.
+		var buf bytes.Buffer
+		for _, mf := range mfs {
+			v, ok := variants[mf.GetName()]
+			if ok && openMetricsOnly(v.Type) {
+				writeOpenMetricsVariant(&buf, v)
+				continue
+			}
+			// prometheus.Gatherers drops units when merging registries.
+			if ok && v.Unit != "" {
+				mf.Unit = &v.Unit
+			}
+			if _, err := expfmt.MetricFamilyToOpenMetrics(&buf, mf, opts...); err != nil {
+				http.Error(w, "Error encoding metrics: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		_, _ = expfmt.FinalizeOpenMetrics(&buf)
+		w.Header().Set("Content-Type", string(format))
+		_, _ = buf.WriteTo(w)
+	})
+}
+
+// writeOpenMetricsVariant writes an info, stateset or gauge histogram
+// family in the OpenMetrics text format.
+func writeOpenMetricsVariant(w io.Writer, v MetricVariant) {
+	name := v.Name
+	if v.Type == variantGaugeHistogram && v.Unit != "" && !strings.HasSuffix(name, "_"+v.Unit) {
+		name += "_" + v.Unit
+	}
+	if v.Help != "" {
+		fmt.Fprintf(w, "# HELP %s %s\n", name, escapeOpenMetrics(v.Help))
+	}
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, v.Type)
+	// Infos and statesets must not have a unit.
+	if v.Type == variantGaugeHistogram && v.Unit != "" {
+		fmt.Fprintf(w, "# UNIT %s %s\n", name, v.Unit)
+	}
+	switch v.Type {
+	case variantInfo:
+		fmt.Fprintf(w, "%s_info%s 1\n", name, formatLabels(v.Labels))
+	case variantStateSet:
+		for _, state := range v.States {
+			fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(v.Labels, name, state), formatFloat(v.stateValue(state)))
+		}
+	case variantGaugeHistogram:
+		count, sum, buckets := v.histogram()
+		for i, upper := range v.Buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name, formatLabels(v.Labels, "le", formatFloat(upper)), buckets[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, formatLabels(v.Labels, "le", "+Inf"), count)
+		fmt.Fprintf(w, "%s_gcount%s %d\n", name, formatLabels(v.Labels), count)
+		fmt.Fprintf(w, "%s_gsum%s %s\n", name, formatLabels(v.Labels), formatFloat(sum))
+	}
+}
+
+// formatLabels renders labels plus the extra name/value pairs as a sorted
+// OpenMetrics label set, or nothing if there are none.
+func formatLabels(labels map[string]string, extra ...string) string {
+	var pairs []string
+	for k, v := range labels {
+		pairs = append(pairs, k+`="`+escapeOpenMetrics(v)+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeOpenMetrics(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var openMetricsEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeOpenMetrics(s string) string {
+	return openMetricsEscaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
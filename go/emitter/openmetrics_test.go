@@ -0,0 +1,69 @@
+package emitter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+const openMetricsAccept = "application/openmetrics-text; version=1.0.0"
+
+func scrapeOpenMetrics(h http.Handler) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", openMetricsAccept)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestMetricsHandlerOpenMetrics(t *testing.T) {
+	a := newTestApp(t, Config{}, WithOpenMetrics(true, false))
+	postIncrement(a.Handler("http"), "/a", `{"incrementBy":3}`)
+	w := scrapeOpenMetrics(a.Handler("metrics"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `erik_prom_path_increment_count_total{path="/a"} 3`) {
+		t.Errorf("missing the increment:\n%s", body)
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Errorf("exposition does not end in # EOF:\n%s", body)
+	}
+}
+
+// TestMetricsHandlerEncodingError checks a family that cannot be encoded
+// fails the whole scrape instead of truncating it.
+func TestMetricsHandlerEncodingError(t *testing.T) {
+	a := newTestApp(t, Config{}, WithOpenMetrics(true, false))
+	// Enough output to fill any write buffer before the bad family.
+	good := &dto.MetricFamily{Name: proto.String("good"), Type: dto.MetricType_GAUGE.Enum()}
+	for i := range 1000 {
+		good.Metric = append(good.Metric, &dto.Metric{
+			Label: []*dto.LabelPair{{Name: proto.String("i"), Value: proto.String(strconv.Itoa(i))}},
+			Gauge: &dto.Gauge{Value: proto.Float64(1)},
+		})
+	}
+	// A counter family whose metric has no counter cannot be encoded.
+	bad := &dto.MetricFamily{
+		Name:   proto.String("bad"),
+		Type:   dto.MetricType_COUNTER.Enum(),
+		Metric: []*dto.Metric{{Gauge: &dto.Gauge{Value: proto.Float64(1)}}},
+	}
+	g := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return []*dto.MetricFamily{good, bad}, nil
+	})
+	w := scrapeOpenMetrics(a.metricsHandler(g, ""))
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d, want 500", w.Code)
+	}
+	if body := w.Body.String(); strings.Contains(body, "# TYPE good") {
+		t.Errorf("error response carries a partial exposition:\n%s", body)
+	}
+}
@@ -0,0 +1,487 @@
+package emitter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Replay cadences.
+const (
+	CadenceOriginal    = "original"
+	CadenceAccelerated = "accelerated"
+	CadenceASAP        = "asap"
+)
+
+// Replay file formats, as written by the collector's file exporter.
+const (
+	ReplayFormatJSON  = "json"
+	ReplayFormatProto = "proto"
+)
+
+const (
+	defaultReplaySpeed = 10
+	// maxReplayMessage bounds one recorded request.
+	maxReplayMessage = 64 << 20
+)
+
+// ReplayOptions controls how recorded OTLP requests are re-sent.
+type ReplayOptions struct {
+	// Cadence is original, the default, which keeps the recorded spacing
+	// between requests, accelerated, which divides it by Speed, or asap.
+	Cadence string `json:"cadence,omitempty"`
+	// Speed defaults to 10.
+	Speed float64 `json:"speed,omitempty"`
+	// Rebase shifts every timestamp by one offset so the last request is
+	// stamped with the time it is sent. Spacing is kept, so cumulative
+	// series stay continuous and nothing is stamped in the future.
+	Rebase bool `json:"rebase,omitempty"`
+	// Resource sets these attributes on every resource; an empty value
+	// removes the attribute.
+	Resource map[string]string `json:"resource,omitempty"`
+	// Tenant sends the requests with that tenant's export headers.
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// ReplayConfig replays a file once at startup.
+type ReplayConfig struct {
+	// File is read as OTLP JSON lines if it ends in .json or .jsonl and
+	// as length-prefixed protobuf otherwise.
+	File string `json:"file"`
+	ReplayOptions
+}
+
+func (c ReplayConfig) validate() error {
+	if c.File == "" {
+		return errors.New("replay needs a file")
+	}
+	return c.ReplayOptions.validate()
+}
+
+func (o ReplayOptions) validate() error {
+	switch o.Cadence {
+	case "", CadenceOriginal, CadenceAccelerated, CadenceASAP:
+	default:
+		return fmt.Errorf("unknown cadence %q, expected %s, %s or %s", o.Cadence, CadenceOriginal, CadenceAccelerated, CadenceASAP)
+	}
+	if o.Speed < 0 {
+		return fmt.Errorf("replay speed must not be negative, got %g", o.Speed)
+	}
+	return nil
+}
+
+type ReplayRecord struct {
+	Source   string    `json:"source"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+	Requests int       `json:"requests"`
+	Failed   int       `json:"failed"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Replay reads recorded OTLP requests from r in the given format and sends
+// them to the OTLP endpoint, returning once all have been sent. It always
+// uses gRPC to the endpoint, even when WithExporter replaced the exporter.
+func (a *App) Replay(ctx context.Context, source string, r io.Reader, format string, opts ReplayOptions) (ReplayRecord, error) {
+	if err := opts.validate(); err != nil {
+		return ReplayRecord{}, err
+	}
+	reqs, err := readOTLPRequests(r, format)
+	if err != nil {
+		return ReplayRecord{}, err
+	}
+	return a.replay(ctx, source, reqs, opts), nil
+}
+
+// replayFormat picks the format of a recorded file by its extension.
+func replayFormat(name string) string {
+	switch filepath.Ext(name) {
+	case ".json", ".jsonl":
+		return ReplayFormatJSON
+	}
+	return ReplayFormatProto
+}
+
+// readOTLPRequests parses JSON lines, one ExportMetricsServiceRequest per
+// line, or protobuf requests each preceded by a big-endian uint32 length.
+// Exemplar trace and span IDs may be the collector's hex or the base64
+// protojson expects.
+func readOTLPRequests(r io.Reader, format string) ([]*colmetricpb.ExportMetricsServiceRequest, error) {
+	var reqs []*colmetricpb.ExportMetricsServiceRequest
+	switch format {
+	case ReplayFormatJSON:
+		sc := bufio.NewScanner(r)
+		sc.Buffer(nil, maxReplayMessage)
+		for line := 1; sc.Scan(); line++ {
+			if len(sc.Bytes()) == 0 {
+				continue
+			}
+			b, err := hexExemplarIDsToBase64(sc.Bytes())
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			req := &colmetricpb.ExportMetricsServiceRequest{}
+			if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(b, req); err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			reqs = append(reqs, req)
+		}
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+	case ReplayFormatProto:
+		br := bufio.NewReader(r)
+		for {
+			var size uint32
+			if err := binary.Read(br, binary.BigEndian, &size); errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			if size > maxReplayMessage {
+				return nil, fmt.Errorf("request %d is %d bytes, over the %d limit", len(reqs)+1, size, maxReplayMessage)
+			}
+			buf := make([]byte, size)
+			if _, err := io.ReadFull(br, buf); err != nil {
+				return nil, fmt.Errorf("request %d: %w", len(reqs)+1, err)
+			}
+			req := &colmetricpb.ExportMetricsServiceRequest{}
+			if err := proto.Unmarshal(buf, req); err != nil {
+				return nil, fmt.Errorf("request %d: %w", len(reqs)+1, err)
+			}
+			reqs = append(reqs, req)
+		}
+	default:
+		return nil, fmt.Errorf("unknown replay format %q, expected %s or %s", format, ReplayFormatJSON, ReplayFormatProto)
+	}
+	if len(reqs) == 0 {
+		return nil, errors.New("no OTLP requests found")
+	}
+	return reqs, nil
+}
+
+// exemplarIDSizes are the byte lengths of the exemplar ID fields, under
+// both names protojson accepts.
+var exemplarIDSizes = map[string]int{"traceId": 16, "trace_id": 16, "spanId": 8, "span_id": 8}
+
+// hexExemplarIDsToBase64 rewrites the hex exemplar IDs in one JSON request
+// as base64. An ID that is not hex of the right length is left for
+// protojson to read as base64; hex is tried first because every hex ID is
+// also valid base64, just of the wrong length.
+func hexExemplarIDsToBase64(line []byte) ([]byte, error) {
+	if !bytes.Contains(line, []byte(`"exemplars"`)) {
+		return line, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(line))
+	// Numbers are kept as written so large ones survive the round trip.
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	rewriteExemplarIDs(v, false)
+	return json.Marshal(v)
+}
+
+// rewriteExemplarIDs walks v, a decoded JSON value, rewriting the IDs of
+// the exemplars in it. inExemplars is whether v is an exemplars list or
+// one of its exemplars.
+func rewriteExemplarIDs(v any, inExemplars bool) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, child := range v {
+			if s, ok := child.(string); ok && inExemplars {
+				if id, err := hex.DecodeString(s); err == nil && len(id) == exemplarIDSizes[k] {
+					v[k] = base64.StdEncoding.EncodeToString(id)
+				}
+				continue
+			}
+			rewriteExemplarIDs(child, k == "exemplars")
+		}
+	case []any:
+		for _, child := range v {
+			rewriteExemplarIDs(child, inExemplars)
+		}
+	}
+}
+
+// replay sends reqs in order, sleeping between them as the cadence says,
+// until done or ctx is cancelled.
+func (a *App) replay(ctx context.Context, source string, reqs []*colmetricpb.ExportMetricsServiceRequest, opts ReplayOptions) (rec ReplayRecord) {
+	rec = ReplayRecord{Source: source, Started: time.Now()}
+	defer func() {
+		rec.Finished = time.Now()
+		a.replays.add(rec)
+		log.Printf("Replayed %d of %d requests from %s", rec.Requests-rec.Failed, rec.Requests, source)
+	}()
+
+	conn, err := grpc.NewClient(a.otlpEndpoint, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		rec.Error = err.Error()
+		return rec
+	}
+	defer conn.Close()
+	client := colmetricpb.NewMetricsServiceClient(conn)
+	md := metadata.New(a.exportHeaders(opts.Tenant))
+
+	speed := replaySpeed(opts.Cadence, opts.Speed)
+
+	times := requestTimes(reqs)
+	var offset time.Duration
+	if opts.Rebase {
+		// The last request goes out after the recorded span scaled by speed.
+		span := times[len(times)-1].Sub(times[0])
+		sendLast := rec.Started
+		if speed > 0 {
+			sendLast = sendLast.Add(time.Duration(float64(span) / speed))
+		}
+		offset = sendLast.Sub(times[len(times)-1])
+	}
+
+	for i, req := range reqs {
+		if i > 0 {
+			if err := a.replayWait(ctx, times[i].Sub(times[i-1]), speed); err != nil {
+				rec.Error = err.Error()
+				return rec
+			}
+		}
+		rewriteRequest(req, offset, opts.Resource)
+		start := time.Now()
+		_, err := client.Export(metadata.NewOutgoingContext(ctx, md), req)
+		exp := ExportRecord{Time: start, DurationMs: float64(time.Since(start).Microseconds()) / 1000}
+		for _, rm := range req.ResourceMetrics {
+			for _, sm := range rm.ScopeMetrics {
+				exp.Metrics += len(sm.Metrics)
+				for _, m := range sm.Metrics {
+					exp.DataPoints += len(dataPoints(m))
+				}
+			}
+		}
+		rec.Requests++
+		if err != nil {
+			exp.Error = err.Error()
+			rec.Failed++
+			rec.Error = err.Error()
+		}
+		a.exports.add(exp)
+	}
+	return rec
+}
+
+// replaySpeed is how much faster than recorded a cadence replays, with 0
+// meaning no waiting at all.
+func replaySpeed(cadence string, speed float64) float64 {
+	switch cadence {
+	case CadenceAccelerated:
+		if speed == 0 {
+			return defaultReplaySpeed
+		}
+		return speed
+	case CadenceASAP:
+		return 0
+	}
+	return 1
+}
+
+// replayWait sleeps for the recorded gap d scaled by speed, failing if ctx
+// is cancelled or the app shuts down first.
+func (a *App) replayWait(ctx context.Context, d time.Duration, speed float64) error {
+	if speed == 0 || d <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(time.Duration(float64(d) / speed)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-a.done:
+		return errors.New("app shut down")
+	}
+}
+
+// loadReplay parses the configured file, returning the replay to run in
+// the background.
+func (a *App) loadReplay(cfg ReplayConfig) (func(), error) {
+	f, err := os.Open(cfg.File)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	reqs, err := readOTLPRequests(f, replayFormat(cfg.File))
+	if err != nil {
+		return nil, fmt.Errorf("replay %s: %w", cfg.File, err)
+	}
+	return func() {
+		log.Printf("Replaying %d OTLP requests from %s", len(reqs), cfg.File)
+		a.replay(context.Background(), cfg.File, reqs, cfg.ReplayOptions)
+	}, nil
+}
+
+// dataPoint is the part of every OTLP data point type replay touches.
+type dataPoint interface {
+	GetTimeUnixNano() uint64
+}
+
+func dataPoints(m *metricpb.Metric) []dataPoint {
+	var out []dataPoint
+	switch d := m.Data.(type) {
+	case *metricpb.Metric_Gauge:
+		for _, dp := range d.Gauge.DataPoints {
+			out = append(out, dp)
+		}
+	case *metricpb.Metric_Sum:
+		for _, dp := range d.Sum.DataPoints {
+			out = append(out, dp)
+		}
+	case *metricpb.Metric_Histogram:
+		for _, dp := range d.Histogram.DataPoints {
+			out = append(out, dp)
+		}
+	case *metricpb.Metric_ExponentialHistogram:
+		for _, dp := range d.ExponentialHistogram.DataPoints {
+			out = append(out, dp)
+		}
+	case *metricpb.Metric_Summary:
+		for _, dp := range d.Summary.DataPoints {
+			out = append(out, dp)
+		}
+	}
+	return out
+}
+
+// requestTimes is when each of reqs was exported. A request without data
+// points takes the time of the one before it, or of the first with data
+// points, so it adds no gap of its own.
+func requestTimes(reqs []*colmetricpb.ExportMetricsServiceRequest) []time.Time {
+	var prev time.Time
+	for _, req := range reqs {
+		if t, ok := requestTime(req); ok {
+			prev = t
+			break
+		}
+	}
+	times := make([]time.Time, len(reqs))
+	for i, req := range reqs {
+		if t, ok := requestTime(req); ok {
+			prev = t
+		}
+		times[i] = prev
+	}
+	return times
+}
+
+// requestTime is the latest data point time in req, i.e. roughly when it
+// was exported. It is false if req has no data points.
+func requestTime(req *colmetricpb.ExportMetricsServiceRequest) (time.Time, bool) {
+	var latest uint64
+	for _, rm := range req.ResourceMetrics {
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				for _, dp := range dataPoints(m) {
+					latest = max(latest, dp.GetTimeUnixNano())
+				}
+			}
+		}
+	}
+	if latest == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(latest)), true
+}
+
+// rewriteRequest shifts every timestamp in req by offset and applies the
+// resource attribute rewrites.
+func rewriteRequest(req *colmetricpb.ExportMetricsServiceRequest, offset time.Duration, resource map[string]string) {
+	shift := func(ts *uint64) {
+		if *ts != 0 {
+			*ts = uint64(int64(*ts) + int64(offset))
+		}
+	}
+	shiftExemplars := func(exs []*metricpb.Exemplar) {
+		for _, ex := range exs {
+			shift(&ex.TimeUnixNano)
+		}
+	}
+	for _, rm := range req.ResourceMetrics {
+		if len(resource) > 0 {
+			rewriteResource(rm, resource)
+		}
+		if offset == 0 {
+			continue
+		}
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				switch d := m.Data.(type) {
+				case *metricpb.Metric_Gauge:
+					for _, dp := range d.Gauge.DataPoints {
+						shift(&dp.StartTimeUnixNano)
+						shift(&dp.TimeUnixNano)
+						shiftExemplars(dp.Exemplars)
+					}
+				case *metricpb.Metric_Sum:
+					for _, dp := range d.Sum.DataPoints {
+						shift(&dp.StartTimeUnixNano)
+						shift(&dp.TimeUnixNano)
+						shiftExemplars(dp.Exemplars)
+					}
+				case *metricpb.Metric_Histogram:
+					for _, dp := range d.Histogram.DataPoints {
+						shift(&dp.StartTimeUnixNano)
+						shift(&dp.TimeUnixNano)
+						shiftExemplars(dp.Exemplars)
+					}
+				case *metricpb.Metric_ExponentialHistogram:
+					for _, dp := range d.ExponentialHistogram.DataPoints {
+						shift(&dp.StartTimeUnixNano)
+						shift(&dp.TimeUnixNano)
+						shiftExemplars(dp.Exemplars)
+					}
+				case *metricpb.Metric_Summary:
+					for _, dp := range d.Summary.DataPoints {
+						shift(&dp.StartTimeUnixNano)
+						shift(&dp.TimeUnixNano)
+					}
+				}
+			}
+		}
+	}
+}
+
+func rewriteResource(rm *metricpb.ResourceMetrics, attrs map[string]string) {
+	if rm.Resource == nil {
+		rm.Resource = &resourcepb.Resource{}
+	}
+	kept := rm.Resource.Attributes[:0]
+	for _, kv := range rm.Resource.Attributes {
+		if _, ok := attrs[kv.Key]; !ok {
+			kept = append(kept, kv)
+		}
+	}
+	for k, v := range attrs {
+		if v != "" {
+			kept = append(kept, &commonpb.KeyValue{Key: k, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}})
+		}
+	}
+	rm.Resource.Attributes = kept
+}
@@ -0,0 +1,103 @@
+package emitter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// sumRequest is a request with one sum point at ts, none if ts is zero.
+func sumRequest(ts uint64) *colmetricpb.ExportMetricsServiceRequest {
+	sum := &metricpb.Sum{}
+	if ts != 0 {
+		sum.DataPoints = []*metricpb.NumberDataPoint{{TimeUnixNano: ts, Value: &metricpb.NumberDataPoint_AsInt{AsInt: 1}}}
+	}
+	return &colmetricpb.ExportMetricsServiceRequest{ResourceMetrics: []*metricpb.ResourceMetrics{{
+		ScopeMetrics: []*metricpb.ScopeMetrics{{Metrics: []*metricpb.Metric{{Name: "s", Data: &metricpb.Metric_Sum{Sum: sum}}}}},
+	}}}
+}
+
+func TestReadOTLPRequestsExemplarIDs(t *testing.T) {
+	const (
+		traceID = "0102030405060708090a0b0c0d0e0f10"
+		spanID  = "0102030405060708"
+	)
+	for _, tc := range []struct{ name, trace, span string }{
+		{"hex", traceID, spanID},
+		{"base64", "AQIDBAUGBwgJCgsMDQ4PEA==", "AQIDBAUGBwg="},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			line := `{"resourceMetrics":[{"scopeMetrics":[{"metrics":[{"name":"s","sum":{"dataPoints":[{"timeUnixNano":"18446744073709551615","asInt":"1",` +
+				`"exemplars":[{"asInt":"1","traceId":"` + tc.trace + `","spanId":"` + tc.span + `"}]}]}}]}]}]}`
+			reqs, err := readOTLPRequests(strings.NewReader(line+"\n\n"), ReplayFormatJSON)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dp := reqs[0].ResourceMetrics[0].ScopeMetrics[0].Metrics[0].GetSum().DataPoints[0]
+			if dp.TimeUnixNano != 18446744073709551615 {
+				t.Errorf("time = %d, want it unchanged", dp.TimeUnixNano)
+			}
+			ex := dp.Exemplars[0]
+			if got := hex.EncodeToString(ex.TraceId); got != traceID {
+				t.Errorf("trace ID = %s, want %s", got, traceID)
+			}
+			if got := hex.EncodeToString(ex.SpanId); got != spanID {
+				t.Errorf("span ID = %s, want %s", got, spanID)
+			}
+		})
+	}
+}
+
+func TestReadOTLPRequestsProto(t *testing.T) {
+	var buf bytes.Buffer
+	for _, ts := range []uint64{1, 2} {
+		b, err := proto.Marshal(sumRequest(ts))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = binary.Write(&buf, binary.BigEndian, uint32(len(b)))
+		buf.Write(b)
+	}
+	reqs, err := readOTLPRequests(&buf, ReplayFormatProto)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reqs) != 2 || !proto.Equal(reqs[1], sumRequest(2)) {
+		t.Errorf("read %v", reqs)
+	}
+}
+
+func TestReadOTLPRequestsErrors(t *testing.T) {
+	for _, tc := range []struct{ name, input, format, wantErr string }{
+		{"empty", "\n", ReplayFormatJSON, "no OTLP requests"},
+		{"bad json", "{\n", ReplayFormatJSON, "line 1"},
+		{"truncated proto", "\x00\x00\x00\x09abc", ReplayFormatProto, "request 1"},
+		{"oversized proto", "\xff\xff\xff\xff", ReplayFormatProto, "over the"},
+		{"unknown format", "", "xml", "unknown replay format"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := readOTLPRequests(strings.NewReader(tc.input), tc.format)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("err = %v, want one containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequestTimes(t *testing.T) {
+	sec := uint64(time.Second)
+	reqs := []*colmetricpb.ExportMetricsServiceRequest{sumRequest(0), sumRequest(5 * sec), sumRequest(0), sumRequest(7 * sec)}
+	want := []time.Duration{5, 5, 5, 7}
+	for i, got := range requestTimes(reqs) {
+		if w := time.Unix(0, int64(want[i]*time.Second)); !got.Equal(w) {
+			t.Errorf("request %d: time = %v, want %v", i, got, w)
+		}
+	}
+}
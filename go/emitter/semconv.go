@@ -0,0 +1,369 @@
+package emitter
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv117 "go.opentelemetry.io/otel/semconv/v1.17.0"
+	semconv120 "go.opentelemetry.io/otel/semconv/v1.20.0"
+	semconv126 "go.opentelemetry.io/otel/semconv/v1.26.0"
+	semconv137 "go.opentelemetry.io/otel/semconv/v1.37.0"
+	semconv14 "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultSemconvVersion is the version otelhttp and the SDK emit.
+const defaultSemconvVersion = "1.37.0"
+
+// SemconvConfig selects the semantic conventions the app's telemetry
+// follows, for exercising a backend's schema translation with producers
+// of older and newer conventions.
+type SemconvConfig struct {
+	// Version is one of 1.4.0, 1.17.0, 1.20.0, 1.26.0 and 1.37.0, the
+	// default. It sets the schema URL of the resource and every scope, and
+	// before 1.21.0, when HTTP conventions were stabilised, the otelhttp
+	// metrics and spans are renamed to that version's HTTP attributes and
+	// http.server.duration style metrics in milliseconds, dropping the
+	// attributes it had no name for.
+	Version string `json:"version,omitempty"`
+}
+
+func (c SemconvConfig) validate() error {
+	if c.Version != "" && semconvVersions[c.Version] == nil {
+		return fmt.Errorf("unknown semconv version %q, expected one of %v", c.Version, semconvVersionNames)
+	}
+	return nil
+}
+
+// semconvVersion is what a version changes about the app's telemetry.
+type semconvVersion struct {
+	schemaURL string
+	// Keys of the service resource attributes.
+	serviceName, serviceVersion, serviceInstanceID attribute.Key
+	// server and client rename the attributes of otelhttp's server and
+	// client telemetry, which follows defaultSemconvVersion. Keys renamed
+	// to "" are dropped: they were added when HTTP conventions were
+	// stabilised and the version has no name for them. Attributes not
+	// listed, such as http.route, are kept.
+	server, client map[attribute.Key]attribute.Key
+	// legacyMetrics renames the otelhttp metrics to their names before
+	// 1.21.0 and reports durations in milliseconds.
+	legacyMetrics bool
+}
+
+// translates reports whether otelhttp's telemetry needs rewriting.
+func (v *semconvVersion) translates() bool {
+	return v.legacyMetrics || len(v.server) > 0 || len(v.client) > 0
+}
+
+// semconvVersionNames lists the supported versions, oldest first.
+var semconvVersionNames = []string{"1.4.0", "1.17.0", "1.20.0", "1.26.0", "1.37.0"}
+
+var semconvVersions = map[string]*semconvVersion{
+	"1.4.0": {
+		schemaURL:         semconv14.SchemaURL,
+		serviceName:       semconv14.ServiceNameKey,
+		serviceVersion:    semconv14.ServiceVersionKey,
+		serviceInstanceID: semconv14.ServiceInstanceIDKey,
+		server: legacyHTTPAttributes(map[attribute.Key]attribute.Key{
+			semconv137.ServerAddressKey:          semconv14.NetHostNameKey,
+			semconv137.ServerPortKey:             semconv14.NetHostPortKey,
+			semconv137.NetworkPeerAddressKey:     semconv14.NetPeerIPKey,
+			semconv137.NetworkPeerPortKey:        semconv14.NetPeerPortKey,
+			semconv137.ClientAddressKey:          semconv14.HTTPClientIPKey,
+			semconv137.NetworkProtocolVersionKey: semconv14.HTTPFlavorKey,
+			semconv137.UserAgentOriginalKey:      semconv14.HTTPUserAgentKey,
+		}),
+		client: legacyHTTPAttributes(map[attribute.Key]attribute.Key{
+			semconv137.ServerAddressKey:          semconv14.NetPeerNameKey,
+			semconv137.ServerPortKey:             semconv14.NetPeerPortKey,
+			semconv137.NetworkProtocolVersionKey: semconv14.HTTPFlavorKey,
+			semconv137.UserAgentOriginalKey:      semconv14.HTTPUserAgentKey,
+		}),
+		legacyMetrics: true,
+	},
+	"1.17.0": {
+		schemaURL:         semconv117.SchemaURL,
+		serviceName:       semconv117.ServiceNameKey,
+		serviceVersion:    semconv117.ServiceVersionKey,
+		serviceInstanceID: semconv117.ServiceInstanceIDKey,
+		server: legacyHTTPAttributes(map[attribute.Key]attribute.Key{
+			semconv137.ServerAddressKey:          semconv117.NetHostNameKey,
+			semconv137.ServerPortKey:             semconv117.NetHostPortKey,
+			semconv137.NetworkPeerAddressKey:     semconv117.NetSockPeerAddrKey,
+			semconv137.NetworkPeerPortKey:        semconv117.NetSockPeerPortKey,
+			semconv137.ClientAddressKey:          semconv117.HTTPClientIPKey,
+			semconv137.NetworkProtocolVersionKey: semconv117.HTTPFlavorKey,
+			semconv137.UserAgentOriginalKey:      semconv117.HTTPUserAgentKey,
+		}),
+		client: legacyHTTPAttributes(map[attribute.Key]attribute.Key{
+			semconv137.ServerAddressKey:          semconv117.NetPeerNameKey,
+			semconv137.ServerPortKey:             semconv117.NetPeerPortKey,
+			semconv137.NetworkProtocolVersionKey: semconv117.HTTPFlavorKey,
+			semconv137.UserAgentOriginalKey:      semconv117.HTTPUserAgentKey,
+		}),
+		legacyMetrics: true,
+	},
+	"1.20.0": {
+		schemaURL:         semconv120.SchemaURL,
+		serviceName:       semconv120.ServiceNameKey,
+		serviceVersion:    semconv120.ServiceVersionKey,
+		serviceInstanceID: semconv120.ServiceInstanceIDKey,
+		server: legacyHTTPAttributes(map[attribute.Key]attribute.Key{
+			semconv137.ServerAddressKey:          semconv120.NetHostNameKey,
+			semconv137.ServerPortKey:             semconv120.NetHostPortKey,
+			semconv137.NetworkPeerAddressKey:     semconv120.NetSockPeerAddrKey,
+			semconv137.NetworkPeerPortKey:        semconv120.NetSockPeerPortKey,
+			semconv137.ClientAddressKey:          semconv120.HTTPClientIPKey,
+			semconv137.NetworkProtocolNameKey:    semconv120.NetProtocolNameKey,
+			semconv137.NetworkProtocolVersionKey: semconv120.NetProtocolVersionKey,
+		}),
+		client: legacyHTTPAttributes(map[attribute.Key]attribute.Key{
+			semconv137.ServerAddressKey:          semconv120.NetPeerNameKey,
+			semconv137.ServerPortKey:             semconv120.NetPeerPortKey,
+			semconv137.NetworkProtocolNameKey:    semconv120.NetProtocolNameKey,
+			semconv137.NetworkProtocolVersionKey: semconv120.NetProtocolVersionKey,
+		}),
+		legacyMetrics: true,
+	},
+	"1.26.0": {
+		schemaURL:         semconv126.SchemaURL,
+		serviceName:       semconv126.ServiceNameKey,
+		serviceVersion:    semconv126.ServiceVersionKey,
+		serviceInstanceID: semconv126.ServiceInstanceIDKey,
+	},
+	"1.37.0": {
+		schemaURL:         semconv137.SchemaURL,
+		serviceName:       semconv137.ServiceNameKey,
+		serviceVersion:    semconv137.ServiceVersionKey,
+		serviceInstanceID: semconv137.ServiceInstanceIDKey,
+	},
+}
+
+// stableHTTPKeys are the attributes introduced when HTTP conventions were
+// stabilised in 1.21.0 that otelhttp can set.
+var stableHTTPKeys = []attribute.Key{
+	semconv137.HTTPRequestMethodKey,
+	semconv137.HTTPRequestMethodOriginalKey,
+	semconv137.HTTPResponseStatusCodeKey,
+	semconv137.HTTPRequestBodySizeKey,
+	semconv137.HTTPResponseBodySizeKey,
+	semconv137.URLSchemeKey,
+	semconv137.URLPathKey,
+	semconv137.URLQueryKey,
+	semconv137.URLFullKey,
+	semconv137.ServerAddressKey,
+	semconv137.ServerPortKey,
+	semconv137.ClientAddressKey,
+	semconv137.ClientPortKey,
+	semconv137.NetworkPeerAddressKey,
+	semconv137.NetworkPeerPortKey,
+	semconv137.NetworkLocalAddressKey,
+	semconv137.NetworkLocalPortKey,
+	semconv137.NetworkProtocolNameKey,
+	semconv137.NetworkProtocolVersionKey,
+	semconv137.NetworkTransportKey,
+	semconv137.NetworkTypeKey,
+	semconv137.UserAgentOriginalKey,
+	semconv137.ErrorTypeKey,
+}
+
+// legacyHTTPAttributes adds the renames every version before 1.21.0 shares
+// to m, and drops the stable keys left without a name. The 1.4.0 names are
+// the same as 1.17.0's and 1.20.0's.
+func legacyHTTPAttributes(m map[attribute.Key]attribute.Key) map[attribute.Key]attribute.Key {
+	m[semconv137.HTTPRequestMethodKey] = semconv14.HTTPMethodKey
+	m[semconv137.HTTPResponseStatusCodeKey] = semconv14.HTTPStatusCodeKey
+	m[semconv137.URLSchemeKey] = semconv14.HTTPSchemeKey
+	m[semconv137.URLPathKey] = semconv14.HTTPTargetKey
+	m[semconv137.URLFullKey] = semconv14.HTTPURLKey
+	m[semconv137.HTTPRequestBodySizeKey] = semconv14.HTTPRequestContentLengthKey
+	m[semconv137.HTTPResponseBodySizeKey] = semconv14.HTTPResponseContentLengthKey
+	for _, k := range stableHTTPKeys {
+		if _, ok := m[k]; !ok {
+			m[k] = ""
+		}
+	}
+	return m
+}
+
+// semconv returns the configured version.
+func (a *App) semconv() *semconvVersion {
+	if v := a.cfg.Semconv.Version; v != "" {
+		return semconvVersions[v]
+	}
+	return semconvVersions[defaultSemconvVersion]
+}
+
+// renamesAny reports whether renames covers any key of attrs.
+func renamesAny(attrs []attribute.KeyValue, renames map[attribute.Key]attribute.Key) bool {
+	return slices.ContainsFunc(attrs, func(kv attribute.KeyValue) bool {
+		_, ok := renames[kv.Key]
+		return ok
+	})
+}
+
+// renameAttributes returns attrs with the keys in renames replaced and
+// those renamed to "" dropped, or attrs itself if none are.
+func renameAttributes(attrs []attribute.KeyValue, renames map[attribute.Key]attribute.Key) []attribute.KeyValue {
+	if !renamesAny(attrs, renames) {
+		return attrs
+	}
+	out := make([]attribute.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		if to, ok := renames[kv.Key]; ok {
+			if to == "" {
+				continue
+			}
+			kv.Key = to
+		}
+		out = append(out, kv)
+	}
+	return out
+}
+
+func renameSet(set attribute.Set, renames map[attribute.Key]attribute.Key) attribute.Set {
+	attrs := set.ToSlice()
+	if !renamesAny(attrs, renames) {
+		return set
+	}
+	return attribute.NewSet(renameAttributes(attrs, renames)...)
+}
+
+// semconvExporter rewrites metrics to a semconv version: every scope gets
+// its schema URL, and the otelhttp metrics its names. The SDK reuses the
+// collected data, and histogram bounds are shared with the aggregators, so
+// what is rewritten is a copy.
+type semconvExporter struct {
+	sdkmetric.Exporter
+	version *semconvVersion
+}
+
+func (e semconvExporter) Export(ctx context.Context, rm *metricdata.ResourceMetrics) error {
+	out := metricdata.ResourceMetrics{Resource: rm.Resource, ScopeMetrics: make([]metricdata.ScopeMetrics, len(rm.ScopeMetrics))}
+	for i, sm := range rm.ScopeMetrics {
+		sm.Scope.SchemaURL = e.version.schemaURL
+		if e.version.translates() {
+			sm.Metrics = slices.Clone(sm.Metrics)
+			for j := range sm.Metrics {
+				e.translate(&sm.Metrics[j])
+			}
+		}
+		out.ScopeMetrics[i] = sm
+	}
+	return e.Exporter.Export(ctx, &out)
+}
+
+// translate rewrites an otelhttp metric, http.server.* or http.client.*.
+func (e semconvExporter) translate(m *metricdata.Metrics) {
+	var renames map[attribute.Key]attribute.Key
+	switch {
+	case strings.HasPrefix(m.Name, "http.server."):
+		renames = e.version.server
+	case strings.HasPrefix(m.Name, "http.client."):
+		renames = e.version.client
+	default:
+		return
+	}
+	switch data := m.Data.(type) {
+	case metricdata.Histogram[float64]:
+		scale := 1.0
+		if e.version.legacyMetrics && m.Unit == "s" {
+			scale, m.Unit = 1000, "ms"
+		}
+		data.DataPoints = translatePoints(data.DataPoints, renames, scale)
+		m.Data = data
+	case metricdata.Histogram[int64]:
+		data.DataPoints = translatePoints(data.DataPoints, renames, 1)
+		m.Data = data
+	case metricdata.Sum[int64]:
+		data.DataPoints = slices.Clone(data.DataPoints)
+		for i := range data.DataPoints {
+			data.DataPoints[i].Attributes = renameSet(data.DataPoints[i].Attributes, renames)
+		}
+		m.Data = data
+	default:
+		// Exponential histograms cannot be rescaled, so they keep their
+		// name and unit.
+		return
+	}
+	if e.version.legacyMetrics {
+		// http.server.request.duration was http.server.duration, and
+		// http.server.request.body.size http.server.request.size.
+		m.Name = strings.Replace(m.Name, ".request.duration", ".duration", 1)
+		m.Name = strings.Replace(m.Name, ".body.size", ".size", 1)
+	}
+}
+
+// translatePoints copies points with their attributes renamed and their
+// values multiplied by scale.
+func translatePoints[N int64 | float64](points []metricdata.HistogramDataPoint[N], renames map[attribute.Key]attribute.Key, scale N) []metricdata.HistogramDataPoint[N] {
+	out := make([]metricdata.HistogramDataPoint[N], len(points))
+	for i, dp := range points {
+		dp.Attributes = renameSet(dp.Attributes, renames)
+		if scale != 1 {
+			dp.Bounds = slices.Clone(dp.Bounds)
+			for b := range dp.Bounds {
+				dp.Bounds[b] *= float64(scale)
+			}
+			dp.Sum *= scale
+			if v, ok := dp.Min.Value(); ok {
+				dp.Min = metricdata.NewExtrema(v * scale)
+			}
+			if v, ok := dp.Max.Value(); ok {
+				dp.Max = metricdata.NewExtrema(v * scale)
+			}
+			dp.Exemplars = slices.Clone(dp.Exemplars)
+			for x := range dp.Exemplars {
+				dp.Exemplars[x].Value *= scale
+			}
+		}
+		out[i] = dp
+	}
+	return out
+}
+
+// semconvSpanExporter rewrites spans to a semconv version: every scope
+// gets its schema URL, and otelhttp's server and client spans their
+// attribute names.
+type semconvSpanExporter struct {
+	sdktrace.SpanExporter
+	version *semconvVersion
+}
+
+func (e semconvSpanExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	out := make([]sdktrace.ReadOnlySpan, len(spans))
+	for i, s := range spans {
+		span := semconvSpan{ReadOnlySpan: s, attrs: s.Attributes(), scope: s.InstrumentationScope()}
+		span.scope.SchemaURL = e.version.schemaURL
+		switch s.SpanKind() {
+		case trace.SpanKindServer:
+			span.attrs = renameAttributes(span.attrs, e.version.server)
+		case trace.SpanKindClient:
+			span.attrs = renameAttributes(span.attrs, e.version.client)
+		}
+		out[i] = span
+	}
+	return e.SpanExporter.ExportSpans(ctx, out)
+}
+
+// semconvSpan is a span as semconvSpanExporter rewrote it.
+type semconvSpan struct {
+	sdktrace.ReadOnlySpan
+	attrs []attribute.KeyValue
+	scope instrumentation.Scope
+}
+
+func (s semconvSpan) Attributes() []attribute.KeyValue { return s.attrs }
+
+func (s semconvSpan) InstrumentationScope() instrumentation.Scope { return s.scope }
+
+// InstrumentationLibrary is deprecated but still read by some exporters.
+func (s semconvSpan) InstrumentationLibrary() instrumentation.Library { return s.scope }
@@ -0,0 +1,139 @@
+package emitter
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// otelhttpAttributes are server attributes as otelhttp sets them.
+var otelhttpAttributes = []attribute.KeyValue{
+	attribute.String("http.request.method", "GET"),
+	attribute.Int("http.response.status_code", 500),
+	attribute.String("network.protocol.name", "http"),
+	attribute.String("network.protocol.version", "1.1"),
+	attribute.String("http.route", "/a"),
+	attribute.String("error.type", "500"),
+}
+
+// semconvCases are the attributes each version turns otelhttpAttributes
+// into.
+var semconvCases = []struct {
+	version string
+	want    []attribute.KeyValue
+}{
+	{"1.4.0", []attribute.KeyValue{
+		attribute.String("http.flavor", "1.1"),
+		attribute.String("http.method", "GET"),
+		attribute.String("http.route", "/a"),
+		attribute.Int("http.status_code", 500),
+	}},
+	{"1.17.0", []attribute.KeyValue{
+		attribute.String("http.flavor", "1.1"),
+		attribute.String("http.method", "GET"),
+		attribute.String("http.route", "/a"),
+		attribute.Int("http.status_code", 500),
+	}},
+	{"1.20.0", []attribute.KeyValue{
+		attribute.String("http.method", "GET"),
+		attribute.String("http.route", "/a"),
+		attribute.Int("http.status_code", 500),
+		attribute.String("net.protocol.name", "http"),
+		attribute.String("net.protocol.version", "1.1"),
+	}},
+	{"1.26.0", otelhttpAttributes},
+	{"1.37.0", otelhttpAttributes},
+}
+
+func TestSemconvSpanExporter(t *testing.T) {
+	for _, tc := range semconvCases {
+		t.Run(tc.version, func(t *testing.T) {
+			spans := tracetest.NewInMemoryExporter()
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(semconvSpanExporter{spans, semconvVersions[tc.version]}))
+			t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+			for _, kind := range []trace.SpanKind{trace.SpanKindServer, trace.SpanKindInternal} {
+				_, span := tp.Tracer("test").Start(context.Background(), "span", trace.WithSpanKind(kind), trace.WithAttributes(otelhttpAttributes...))
+				span.End()
+			}
+
+			got := spans.GetSpans()
+			if len(got) != 2 {
+				t.Fatalf("%d spans, want 2", len(got))
+			}
+			want, untouched := attribute.NewSet(tc.want...), attribute.NewSet(otelhttpAttributes...)
+			if attrs := attribute.NewSet(got[0].Attributes...); !attrs.Equals(&want) {
+				t.Errorf("server span attributes = %v, want %v", attrs.ToSlice(), tc.want)
+			}
+			if attrs := attribute.NewSet(got[1].Attributes...); !attrs.Equals(&untouched) {
+				t.Errorf("internal span attributes = %v, want them untouched", attrs.ToSlice())
+			}
+			for _, s := range got {
+				if s.InstrumentationScope.SchemaURL != semconvVersions[tc.version].schemaURL {
+					t.Errorf("scope schema URL = %q, want %q", s.InstrumentationScope.SchemaURL, semconvVersions[tc.version].schemaURL)
+				}
+			}
+		})
+	}
+}
+
+func TestSemconvExporter(t *testing.T) {
+	for _, tc := range semconvCases {
+		t.Run(tc.version, func(t *testing.T) {
+			v := semconvVersions[tc.version]
+			wantName, wantUnit, wantBound := "http.server.request.duration", "s", 0.1
+			if v.legacyMetrics {
+				wantName, wantUnit, wantBound = "http.server.duration", "ms", 100
+			}
+			bounds := []float64{0.1}
+			exp := &captureExporter{}
+			err := semconvExporter{exp, v}.Export(context.Background(), &metricdata.ResourceMetrics{
+				ScopeMetrics: []metricdata.ScopeMetrics{{
+					Scope: instrumentation.Scope{Name: "otelhttp"},
+					Metrics: []metricdata.Metrics{{
+						Name: "http.server.request.duration",
+						Unit: "s",
+						Data: metricdata.Histogram[float64]{
+							Temporality: metricdata.CumulativeTemporality,
+							DataPoints: []metricdata.HistogramDataPoint[float64]{{
+								Attributes:   attribute.NewSet(otelhttpAttributes...),
+								Count:        1,
+								Bounds:       bounds,
+								BucketCounts: []uint64{0, 1},
+								Sum:          0.5,
+							}},
+						},
+					}},
+				}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			sm := exp.last.ScopeMetrics[0]
+			if sm.Scope.SchemaURL != v.schemaURL {
+				t.Errorf("scope schema URL = %q, want %q", sm.Scope.SchemaURL, v.schemaURL)
+			}
+			m := sm.Metrics[0]
+			if m.Name != wantName || m.Unit != wantUnit {
+				t.Errorf("metric %s in %s, want %s in %s", m.Name, m.Unit, wantName, wantUnit)
+			}
+			dp := m.Data.(metricdata.Histogram[float64]).DataPoints[0]
+			if !slices.Equal(dp.Bounds, []float64{wantBound}) {
+				t.Errorf("bounds = %v, want [%g]", dp.Bounds, wantBound)
+			}
+			if want := attribute.NewSet(tc.want...); !dp.Attributes.Equals(&want) {
+				t.Errorf("attributes = %v, want %v", dp.Attributes.ToSlice(), tc.want)
+			}
+			if bounds[0] != 0.1 {
+				t.Errorf("the exported bounds were rescaled in place to %v", bounds)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package emitter
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"eriktestapp/controlpb"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// Route names that listeners can reference in config.
+const (
+	RouteIncrement       = "increment"
+	RouteMetrics         = "metrics"
+	RouteForceRestart    = "forcerestart"
+	RouteAPI             = "api"
+	RouteDashboard       = "ui"
+	RouteGRPC            = "grpc"
+	RouteOpenAPI         = "openapi"
+	RouteTenantMetrics   = "tenantmetrics"
+	RouteTargets         = "targets"
+	RouteHTTPSD          = "httpsd"
+	RouteTargetAllocator = "targetallocator"
+	RouteVariants        = "variants"
+	RouteFlush           = "flush"
+)
+
+// routePatterns are the mux patterns each route is mounted at. Most routes
+// have one; some span unrelated paths.
+var routePatterns = map[string][]string{
+	RouteIncrement:       {"/"},
+	RouteMetrics:         {"/metrics"},
+	RouteForceRestart:    {"/forcerestart"},
+	RouteAPI:             {"/api/"},
+	RouteDashboard:       {"/ui/"},
+	RouteGRPC:            {"/" + controlpb.Control_ServiceDesc.ServiceName + "/"},
+	RouteOpenAPI:         {"GET /openapi.json"},
+	RouteTenantMetrics:   {"GET /metrics/{tenant}"},
+	RouteTargets:         {"GET /targets/{n}/metrics"},
+	RouteHTTPSD:          {"GET /sd/targets"},
+	RouteTargetAllocator: {"GET /jobs", "GET /jobs/", "GET /scrape_configs"},
+	RouteVariants:        {"GET /variants/{registry}/metrics"},
+	RouteFlush:           {"POST /flush"},
+}
+
+// newMux builds a dedicated mux for one listener, mounting only the routes
+// assigned to it.
+func newMux(lc ListenerConfig, routes map[string]http.Handler) *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, name := range lc.Routes {
+		for _, p := range routePatterns[name] {
+			mux.Handle(p, routes[name])
+		}
+	}
+	return mux
+}
+
+func listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		// Clear a socket left behind by a previous run.
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// serveListeners starts one server per listener and blocks until ctx is
+// done or any of them fails, then shuts them all down.
+func serveListeners(ctx context.Context, listeners []ListenerConfig, routes map[string]http.Handler) error {
+	var servers []*http.Server
+	defer func() {
+		for _, srv := range servers {
+			_ = srv.Shutdown(context.Background())
+		}
+	}()
+	errc := make(chan error, len(listeners))
+	for _, lc := range listeners {
+		ln, err := listen(lc.Addr)
+		if err != nil {
+			return err
+		}
+		srv := &http.Server{Handler: newMux(lc, routes)}
+		// gRPC needs HTTP/2, which without TLS means accepting h2c.
+		srv.Protocols = new(http.Protocols)
+		srv.Protocols.SetHTTP1(true)
+		srv.Protocols.SetUnencryptedHTTP2(true)
+		servers = append(servers, srv)
+		log.Printf("Starting %s server on %s serving %v", lc.Name, lc.Addr, lc.Routes)
+		go func() {
+			errc <- srv.Serve(ln)
+		}()
+	}
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// routes builds every handler a listener can mount, keyed by route name.
+// The control routes wrap the handlers controlRoutes returns.
+func (a *App) routes() map[string]http.Handler {
+	a.controlHandlers = a.controlRoutes()
+	c := a.controlHandlers
+	return map[string]http.Handler{
+		RouteMetrics: a.recordScrapes(promhttp.InstrumentMetricHandler(
+			a.registerer, a.metricsHandler(a.gatherer, ""),
+		)),
+		RouteTenantMetrics:   http.HandlerFunc(a.handleTenantMetrics),
+		RouteTargets:         http.HandlerFunc(a.handleTargetMetrics),
+		RouteHTTPSD:          http.HandlerFunc(a.handleHTTPSD),
+		RouteTargetAllocator: a.newAllocatorHandler(),
+		RouteVariants:        http.HandlerFunc(a.handleVariantMetrics),
+		RouteFlush:           a.recordControl(RouteFlush, c[RouteFlush]),
+		RouteForceRestart:    a.recordControl(RouteForceRestart, c[RouteForceRestart]),
+		RouteAPI:             a.limitAPI(a.forwardToOwner(RouteAPI, a.recordControl(RouteAPI, c[RouteAPI]))),
+		RouteDashboard:       http.HandlerFunc(handleDashboard),
+		RouteOpenAPI:         http.HandlerFunc(handleOpenAPI),
+		RouteGRPC:            a.newGRPCServer(),
+		RouteIncrement:       a.limitIncrements(a.forwardToOwner(RouteIncrement, a.recordControl(RouteIncrement, c[RouteIncrement]))),
+	}
+}
+
+// controlRoutes builds the handlers of the routes control requests are
+// recorded from, without the recording, limits and forwarding around them.
+// Replays call these directly.
+func (a *App) controlRoutes() map[string]http.Handler {
+	return map[string]http.Handler{
+		RouteFlush:        http.HandlerFunc(a.handleFlush),
+		RouteForceRestart: http.HandlerFunc(a.handleForceRestart),
+		RouteAPI:          a.newAPIHandler(),
+		// POST handler for any path
+		RouteIncrement: otelhttp.NewHandler(&dummyHandler{a}, "test",
+			otelhttp.WithMeterProvider(a.defaultTenant.meterProvider),
+			otelhttp.WithTracerProvider(a.TracerProvider()),
+			otelhttp.WithPropagators(propagator),
+			otelhttp.WithMetricAttributesFn(a.serverMetricAttributes),
+		),
+	}
+}
+
+func (a *App) promHandlerOpts() promhttp.HandlerOpts {
+	return promhttp.HandlerOpts{
+		EnableOpenMetrics:                   a.openMetrics,
+		EnableOpenMetricsTextCreatedSamples: a.createdSamples,
+	}
+}
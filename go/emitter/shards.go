@@ -0,0 +1,52 @@
+package emitter
+
+import "sync"
+
+// numShards is how many locks per-series state is split across.
+const numShards = 64
+
+// shardedMap is a map keyed by series whose keys are spread over shards
+// with a lock each, so requests for different series rarely wait for each
+// other. The zero value is ready to use.
+type shardedMap[V any] struct {
+	shards [numShards]mapShard[V]
+}
+
+type mapShard[V any] struct {
+	mu sync.Mutex
+	m  map[seriesKey]V
+}
+
+func (s *shardedMap[V]) shard(key seriesKey) *mapShard[V] {
+	// FNV-1a, inlined to keep the hot path free of allocations.
+	h := uint32(2166136261)
+	for i := 0; i < len(key.tenant); i++ {
+		h = (h ^ uint32(key.tenant[i])) * 16777619
+	}
+	h *= 16777619
+	for i := 0; i < len(key.path); i++ {
+		h = (h ^ uint32(key.path[i])) * 16777619
+	}
+	return &s.shards[h%numShards]
+}
+
+// with runs fn on the map of key's shard under its lock.
+func (s *shardedMap[V]) with(key seriesKey, fn func(m map[seriesKey]V)) {
+	sh := s.shard(key)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if sh.m == nil {
+		sh.m = make(map[seriesKey]V)
+	}
+	fn(sh.m)
+}
+
+// all runs fn on every shard's map, holding one shard's lock at a time.
+func (s *shardedMap[V]) all(fn func(m map[seriesKey]V)) {
+	for i := range s.shards {
+		sh := &s.shards[i]
+		sh.mu.Lock()
+		fn(sh.m)
+		sh.mu.Unlock()
+	}
+}
@@ -0,0 +1,39 @@
+package emitter
+
+import (
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+func TestShardedMap(t *testing.T) {
+	var m shardedMap[int]
+	for i := range 1000 {
+		key := seriesKey{"t", "/" + strconv.Itoa(i)}
+		m.with(key, func(m map[seriesKey]int) { m[key] = i })
+	}
+	var n int
+	m.all(func(m map[seriesKey]int) {
+		for key, v := range m {
+			if key.path != "/"+strconv.Itoa(v) {
+				t.Errorf("%v = %d", key, v)
+			}
+			n++
+		}
+	})
+	if n != 1000 {
+		t.Errorf("all saw %d keys, want 1000", n)
+	}
+}
+
+func BenchmarkShardedMapWith(b *testing.B) {
+	var m shardedMap[int]
+	var clients atomic.Int64
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		key := seriesKey{"", "/bench-" + strconv.FormatInt(clients.Add(1), 10)}
+		for pb.Next() {
+			m.with(key, func(m map[seriesKey]int) { m[key]++ })
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package emitter
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+const (
+	defaultAllocatorJobName        = "virtual-targets"
+	defaultAllocatorScrapeInterval = "10s"
+)
+
+// allocator emulates the OpenTelemetry Operator target allocator over the
+// virtual targets. Targets are assigned to collectors round-robin by index,
+// so the split is deterministic for a given target count and collector
+// list.
+type allocator struct {
+	jobName        string
+	scrapeInterval string
+
+	mu           sync.Mutex
+	collectorIDs []string
+}
+
+type allocatorLink struct {
+	Link string `json:"_link"`
+}
+
+type collectorTargets struct {
+	Link    string          `json:"_link"`
+	Targets []sdTargetGroup `json:"targets"`
+}
+
+func newAllocator(cfg TargetAllocatorConfig) *allocator {
+	al := &allocator{
+		jobName:        cfg.JobName,
+		scrapeInterval: cfg.ScrapeInterval,
+		collectorIDs:   slices.Clone(cfg.CollectorIDs),
+	}
+	if al.jobName == "" {
+		al.jobName = defaultAllocatorJobName
+	}
+	if al.scrapeInterval == "" {
+		al.scrapeInterval = defaultAllocatorScrapeInterval
+	}
+	if len(al.collectorIDs) == 0 {
+		al.collectorIDs = []string{"collector-0"}
+	}
+	return al
+}
+
+// SetCollectorIDs replaces the collectors virtual targets are split across.
+func (a *App) SetCollectorIDs(ids []string) {
+	a.allocator.mu.Lock()
+	defer a.allocator.mu.Unlock()
+	a.allocator.collectorIDs = slices.Clone(ids)
+}
+
+// CollectorIDs returns the collectors virtual targets are split across.
+func (a *App) CollectorIDs() []string {
+	a.allocator.mu.Lock()
+	defer a.allocator.mu.Unlock()
+	return slices.Clone(a.allocator.collectorIDs)
+}
+
+func (a *App) newAllocatorHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /jobs", a.handleAllocatorJobs)
+	mux.HandleFunc("GET /jobs/{job}/targets", a.handleAllocatorTargets)
+	mux.HandleFunc("GET /scrape_configs", a.handleAllocatorScrapeConfigs)
+	return mux
+}
+
+func (a *App) handleAllocatorJobs(w http.ResponseWriter, r *http.Request) {
+	job := a.allocator.jobName
+	writeJSON(w, http.StatusOK, map[string]allocatorLink{
+		job: {Link: "/jobs/" + url.PathEscape(job) + "/targets"},
+	})
+}
+
+// handleAllocatorTargets returns every collector's share of the job's
+// targets, or with ?collector_id= just that collector's target groups.
+func (a *App) handleAllocatorTargets(w http.ResponseWriter, r *http.Request) {
+	job := r.PathValue("job")
+	if job != a.allocator.jobName {
+		http.Error(w, fmt.Sprintf("Unknown job %q", job), http.StatusNotFound)
+		return
+	}
+	ids := a.CollectorIDs()
+	groups := a.targetGroups(r)
+	assigned := make(map[string][]sdTargetGroup, len(ids))
+	for _, id := range ids {
+		assigned[id] = []sdTargetGroup{}
+	}
+	if len(ids) > 0 {
+		for i, g := range groups {
+			id := ids[i%len(ids)]
+			assigned[id] = append(assigned[id], g)
+		}
+	}
+
+	if id := r.URL.Query().Get("collector_id"); id != "" {
+		if targets, ok := assigned[id]; ok {
+			writeJSON(w, http.StatusOK, targets)
+		} else {
+			writeJSON(w, http.StatusOK, []sdTargetGroup{})
+		}
+		return
+	}
+	resp := make(map[string]collectorTargets, len(ids))
+	for id, targets := range assigned {
+		resp[id] = collectorTargets{
+			Link:    "/jobs/" + url.PathEscape(job) + "/targets?collector_id=" + url.QueryEscape(id),
+			Targets: targets,
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleAllocatorScrapeConfigs returns the Prometheus scrape config of the
+// virtual targets job. Metrics paths come from each target's
+// __metrics_path__ label.
+func (a *App) handleAllocatorScrapeConfigs(w http.ResponseWriter, r *http.Request) {
+	job := a.allocator.jobName
+	writeJSON(w, http.StatusOK, map[string]map[string]any{
+		job: {
+			"job_name":        job,
+			"scrape_interval": a.allocator.scrapeInterval,
+			"scrape_timeout":  a.allocator.scrapeInterval,
+			"metrics_path":    "/metrics",
+			"scheme":          "http",
+			"honor_labels":    false,
+		},
+	})
+}
+
+// targetGroups returns one target group per virtual target, addressed at
+// its owner in cluster mode, otherwise at the configured address or the
+// Host r was sent to.
+func (a *App) targetGroups(r *http.Request) []sdTargetGroup {
+	addr := a.cfg.Targets.Address
+	if addr == "" {
+		addr = r.Host
+	}
+	n := a.TargetCount()
+	groups := make([]sdTargetGroup, 0, n)
+	for i := range n {
+		labels := map[string]string{
+			"__metrics_path__": fmt.Sprintf("/targets/%d/metrics", i),
+			"virtual_target":   strconv.Itoa(i),
+		}
+		for k, v := range a.cfg.Targets.Labels {
+			labels[k] = v
+		}
+		groups = append(groups, sdTargetGroup{Targets: []string{a.targetAddress(i, addr)}, Labels: labels})
+	}
+	return groups
+}
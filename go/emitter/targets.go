@@ -0,0 +1,200 @@
+package emitter
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	defaultSeriesPerTarget = 10
+	defaultTargetIncBy     = 1
+)
+
+// virtualTarget is one simulated scrape target with its own registry,
+// served at /targets/{n}/metrics.
+type virtualTarget struct {
+	index   int
+	counter *prometheus.CounterVec
+	handler http.Handler
+}
+
+// targetSet holds the virtual targets. A single generator goroutine
+// increments every series of every target each interval, so hundreds of
+// targets cost one ticker.
+type targetSet struct {
+	seriesPerTarget int
+	incBy           int
+	interval        time.Duration
+
+	mu      sync.RWMutex
+	targets []*virtualTarget
+}
+
+func newTargetSet(cfg TargetsConfig) *targetSet {
+	ts := &targetSet{
+		seriesPerTarget: cfg.SeriesPerTarget,
+		incBy:           cfg.IncrementBy,
+		interval:        time.Duration(cfg.IntervalSeconds) * time.Second,
+	}
+	if ts.seriesPerTarget <= 0 {
+		ts.seriesPerTarget = defaultSeriesPerTarget
+	}
+	if ts.incBy == 0 {
+		ts.incBy = defaultTargetIncBy
+	}
+	if ts.interval <= 0 {
+		ts.interval = defaultIntervalSecs * time.Second
+	}
+	return ts
+}
+
+func (a *App) newVirtualTarget(index, series int) *virtualTarget {
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: promCounterName,
+			Help: "Running sum of incrementBy values by path",
+		},
+		[]string{"path"},
+	)
+	reg.MustRegister(counter)
+	for i := range series {
+		counter.WithLabelValues(targetSeriesPath(i))
+	}
+	return &virtualTarget{
+		index:   index,
+		counter: counter,
+		handler: a.recordScrapes(promhttp.HandlerFor(reg, a.promHandlerOpts())),
+	}
+}
+
+func targetSeriesPath(i int) string {
+	return fmt.Sprintf("/series-%d", i)
+}
+
+// SetTargetCount grows or shrinks the virtual targets to n. Targets keep
+// their index; removed ones lose their state, so a target that comes back
+// starts from zero like a fresh pod would. It fails if n is negative or
+// over the target cap.
+func (a *App) SetTargetCount(n int) error {
+	if n < 0 {
+		return fmt.Errorf("target count must not be negative, got %d", n)
+	}
+	if limit := a.limits.maxTargets; limit > 0 && n > limit {
+		return fmt.Errorf("target count %d is over the limit of %d", n, limit)
+	}
+	ts := a.targets
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if n == len(ts.targets) {
+		return nil
+	}
+	for len(ts.targets) < n {
+		ts.targets = append(ts.targets, a.newVirtualTarget(len(ts.targets), ts.seriesPerTarget))
+	}
+	ts.targets = ts.targets[:n]
+	log.Printf("Serving %d virtual targets", len(ts.targets))
+	return nil
+}
+
+// TargetCount returns the number of virtual targets.
+func (a *App) TargetCount() int {
+	a.targets.mu.RLock()
+	defer a.targets.mu.RUnlock()
+	return len(a.targets.targets)
+}
+
+func (a *App) target(index int) (*virtualTarget, bool) {
+	a.targets.mu.RLock()
+	defer a.targets.mu.RUnlock()
+	if index < 0 || index >= len(a.targets.targets) {
+		return nil, false
+	}
+	return a.targets.targets[index], true
+}
+
+// runTargetGenerator increments every virtual target's series until done
+// is closed. In cluster mode it skips the targets other replicas own.
+func (a *App) runTargetGenerator() {
+	ts := a.targets
+	ticker := time.NewTicker(ts.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ts.mu.RLock()
+			for _, t := range ts.targets {
+				if _, ok := a.ownsTarget(t.index); !ok {
+					continue
+				}
+				for i := range ts.seriesPerTarget {
+					t.counter.WithLabelValues(targetSeriesPath(i)).Add(float64(ts.incBy))
+				}
+			}
+			ts.mu.RUnlock()
+		case <-a.done:
+			return
+		}
+	}
+}
+
+func (a *App) handleTargetMetrics(w http.ResponseWriter, r *http.Request) {
+	n, err := strconv.Atoi(r.PathValue("n"))
+	if err != nil {
+		http.Error(w, "Target index must be an integer", http.StatusBadRequest)
+		return
+	}
+	t, ok := a.target(n)
+	if !ok {
+		http.Error(w, fmt.Sprintf("No virtual target %d", n), http.StatusNotFound)
+		return
+	}
+	if _, ok := a.ownsTarget(n); !ok {
+		http.Redirect(w, r, "http://"+a.targetAddress(n, r.Host)+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+		return
+	}
+	t.handler.ServeHTTP(w, r)
+}
+
+// sdTargetGroup is one entry of a Prometheus http_sd_configs response.
+type sdTargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// handleHTTPSD lists the virtual targets in the format Prometheus'
+// http_sd_configs expects. Each target is its own group so it can carry
+// its own __metrics_path__.
+func (a *App) handleHTTPSD(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, a.targetGroups(r))
+}
+
+// runTargetChurn moves the target count by up to Step in a random
+// direction every interval, staying within [Min, Max], to simulate
+// autoscaling. In cluster mode only the coordinator churns.
+func (a *App) runTargetChurn(churn ChurnConfig) {
+	step := max(churn.Step, 1)
+	ticker := time.NewTicker(time.Duration(max(churn.IntervalSeconds, 1)) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !a.coordinating() {
+				continue
+			}
+			delta := rand.IntN(2*step+1) - step
+			// Validation keeps churn.Max under the cap.
+			_ = a.SetTargetCount(min(max(a.TargetCount()+delta, churn.Min), churn.Max))
+		case <-a.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package emitter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSetTargetCountCap(t *testing.T) {
+	a := newTestApp(t, Config{Limits: LimitsConfig{MaxTargets: 3}})
+	h := a.Handler("metrics")
+	for _, tc := range []struct {
+		body string
+		want int
+	}{
+		{`{"count":3}`, http.StatusOK},
+		{`{"count":4}`, http.StatusBadRequest},
+		{`{"count":-1}`, http.StatusBadRequest},
+		{`{"count":1}`, http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/targets", strings.NewReader(tc.body)))
+		if w.Code != tc.want {
+			t.Errorf("POST /api/targets %s: status %d, want %d: %s", tc.body, w.Code, tc.want, w.Body)
+		}
+	}
+	if got := a.TargetCount(); got != 1 {
+		t.Errorf("TargetCount() = %d, want 1", got)
+	}
+}
+
+func TestTargetsConfigCap(t *testing.T) {
+	for _, cfg := range []Config{
+		{Targets: TargetsConfig{Count: defaultMaxTargets + 1}},
+		{Targets: TargetsConfig{Churn: &ChurnConfig{Max: 20}}, Limits: LimitsConfig{MaxTargets: 10}},
+	} {
+		cfg.Listeners = DefaultListeners()
+		if err := cfg.validate(); err == nil {
+			t.Errorf("validate(%+v) = nil, want an error", cfg.Targets)
+		}
+	}
+	cfg := Config{Listeners: DefaultListeners(), Targets: TargetsConfig{Count: 5000}, Limits: LimitsConfig{MaxTargets: -1}}
+	if err := cfg.validate(); err != nil {
+		t.Errorf("validate without a cap: %v", err)
+	}
+}
@@ -0,0 +1,266 @@
+package emitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+)
+
+const (
+	otlpSumCounterName = "erik_otlp_path_increment_count"
+	promCounterName    = "erik_prom_path_increment_count_total"
+)
+
+const scopeName = "erik-wu-test-scope"
+
+// defaultTenantHeader is the header tenants are read from on requests and
+// sent with on OTLP exports, as used by Cortex, Mimir and Loki.
+const defaultTenantHeader = "X-Scope-OrgID"
+
+// tenantResourceKey is added to a tenant's resource alongside the usual
+// service attributes.
+const tenantResourceKey = "tenant.id"
+
+// validTenantID follows the characters Mimir accepts in tenant IDs.
+var validTenantID = regexp.MustCompile(`^[a-zA-Z0-9!._*'()-]{1,150}$`)
+
+// tenant owns one isolated stream: a Prometheus registry and a
+// MeterProvider with its own exporter headers and resource.
+type tenant struct {
+	id            string
+	registerer    prometheus.Registerer
+	gatherer      prometheus.Gatherer
+	meterProvider *sdkmetric.MeterProvider
+	exporter      sdkmetric.Exporter
+	resource      *sdkresource.Resource
+	// manualReader is set in manual-reader mode, where nothing is exported
+	// until flush is called.
+	manualReader *sdkmetric.ManualReader
+	// OTLP metric; the Prometheus one is served from the ledger.
+	otlpPathIncrementSum metric.Int64Counter
+	// metricsHandler serves /metrics/{tenant}; nil for the default tenant.
+	metricsHandler http.Handler
+}
+
+// seriesKey identifies a series, and therefore a worker and a ledger
+// entry, across tenants.
+type seriesKey struct {
+	tenant string
+	path   string
+}
+
+func (a *App) newTenant(ctx context.Context, id string, reg prometheus.Registerer, g prometheus.Gatherer) (*tenant, error) {
+	t := &tenant{id: id, registerer: reg, gatherer: g}
+
+	if err := reg.Register(newLedgerCollector(&a.ledger, id)); err != nil {
+		return nil, err
+	}
+
+	if err := a.initOTLPMetrics(ctx, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (a *App) initOTLPMetrics(ctx context.Context, t *tenant) error {
+	var override TenantOverride
+	if t.id != "" {
+		override = a.cfg.Tenants.Overrides[t.id]
+	}
+
+	exporter := a.exporter
+	if exporter == nil {
+		var err error
+		exporter, err = otlpmetricgrpc.New(ctx,
+			otlpmetricgrpc.WithEndpoint(a.otlpEndpoint),
+			otlpmetricgrpc.WithInsecure(),
+			otlpmetricgrpc.WithHeaders(a.exportHeaders(t.id)),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	semconv := a.semconv()
+	attrs := []attribute.KeyValue{
+		semconv.serviceInstanceID.String(a.instanceID),
+		semconv.serviceName.String("erik-test-service"),
+		semconv.serviceVersion.String("1.0.0"),
+	}
+	if t.id != "" {
+		attrs = append(attrs, attribute.String(tenantResourceKey, t.id))
+	}
+	for k, v := range override.ResourceAttributes {
+		attrs = append(attrs, attribute.String(k, v))
+	}
+	// The SDK's defaults are taken over rather than merged, as merging
+	// fails on their differing schema URL.
+	sdkRes := sdkresource.NewWithAttributes(semconv.schemaURL,
+		append(sdkresource.Default().Attributes(), attrs...)...)
+
+	t.resource = sdkRes
+	t.exporter = recordingExporter{startTimeExporter{semconvExporter{exporter, semconv}, a, t.id}, &a.exports}
+	var reader sdkmetric.Reader
+	if a.cfg.SDK.Reader == ReaderManual {
+		t.manualReader = sdkmetric.NewManualReader(
+			sdkmetric.WithTemporalitySelector(t.exporter.Temporality),
+			sdkmetric.WithAggregationSelector(t.exporter.Aggregation),
+		)
+		reader = t.manualReader
+	} else {
+		reader = sdkmetric.NewPeriodicReader(t.exporter, sdkmetric.WithInterval(a.exportInterval))
+	}
+	t.meterProvider = sdkmetric.NewMeterProvider(append([]sdkmetric.Option{
+		sdkmetric.WithResource(sdkRes),
+		sdkmetric.WithReader(reader),
+	}, a.cfg.SDK.meterProviderOptions()...)...)
+
+	meter := t.meterProvider.Meter(
+		scopeName,
+		metric.WithInstrumentationVersion("v1.0.0"),
+		metric.WithSchemaURL(semconv.schemaURL),
+	)
+
+	var err error
+	t.otlpPathIncrementSum, err = meter.Int64Counter(
+		otlpSumCounterName,
+		metric.WithDescription("Running sum of incrementBy values by path"),
+	)
+	return err
+}
+
+// flush exports the tenant's metrics now: a forced flush of the periodic
+// reader, or a collection and export in manual-reader mode.
+func (t *tenant) flush(ctx context.Context) error {
+	if t.manualReader == nil {
+		return t.meterProvider.ForceFlush(ctx)
+	}
+	var rm metricdata.ResourceMetrics
+	if err := t.manualReader.Collect(ctx, &rm); err != nil {
+		return err
+	}
+	return t.exporter.Export(ctx, &rm)
+}
+
+// shutdown stops the tenant's MeterProvider. A manual reader does not own
+// the exporter, so it is shut down separately.
+func (t *tenant) shutdown(ctx context.Context) error {
+	err := t.meterProvider.Shutdown(ctx)
+	if t.manualReader != nil {
+		err = errors.Join(err, t.exporter.Shutdown(ctx))
+	}
+	return err
+}
+
+// exportHeaders returns the headers OTLP exports for tenant id carry: the
+// tenant header plus any configured overrides.
+func (a *App) exportHeaders(id string) map[string]string {
+	if id == "" {
+		return map[string]string{}
+	}
+	override := a.cfg.Tenants.Overrides[id]
+	headers := make(map[string]string, len(override.Headers)+1)
+	headers[a.tenantHeader()] = id
+	for k, v := range override.Headers {
+		headers[k] = v
+	}
+	return headers
+}
+
+func (a *App) tenantHeader() string {
+	if a.cfg.Tenants.Header != "" {
+		return a.cfg.Tenants.Header
+	}
+	return defaultTenantHeader
+}
+
+// tenant returns the tenant called id, creating it on first use. The empty
+// id is the default tenant. Past the tenant cap, creating one fails with a
+// *LimitError unless it has overrides configured.
+func (a *App) tenant(id string) (*tenant, error) {
+	if id == "" {
+		return a.defaultTenant, nil
+	}
+	if !validTenantID.MatchString(id) {
+		return nil, fmt.Errorf("invalid tenant ID %q", id)
+	}
+	if t, ok := a.lookupTenant(id); ok {
+		return t, nil
+	}
+	a.tenantsMu.Lock()
+	defer a.tenantsMu.Unlock()
+	if t, ok := a.tenants[id]; ok {
+		return t, nil
+	}
+	if _, configured := a.cfg.Tenants.Overrides[id]; !configured {
+		if err := a.limits.allowTenant(len(a.tenants)); err != nil {
+			return nil, err
+		}
+	}
+	reg := prometheus.NewRegistry()
+	t, err := a.newTenant(context.Background(), id, reg, reg)
+	if err != nil {
+		return nil, err
+	}
+	t.metricsHandler = a.recordScrapes(promhttp.HandlerFor(reg, a.promHandlerOpts()))
+	a.tenants[id] = t
+	return t, nil
+}
+
+// lookupTenant returns an existing tenant without creating it.
+func (a *App) lookupTenant(id string) (*tenant, bool) {
+	a.tenantsMu.RLock()
+	defer a.tenantsMu.RUnlock()
+	t, ok := a.tenants[id]
+	return t, ok
+}
+
+func (a *App) allTenants() []*tenant {
+	a.tenantsMu.RLock()
+	defer a.tenantsMu.RUnlock()
+	out := make([]*tenant, 0, len(a.tenants))
+	for _, t := range a.tenants {
+		out = append(out, t)
+	}
+	return out
+}
+
+// Tenants returns the IDs of the tenants seen so far, sorted.
+func (a *App) Tenants() []string {
+	var ids []string
+	for _, t := range a.allTenants() {
+		ids = append(ids, t.id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// requestTenant picks the tenant for an HTTP request: the payload field if
+// set, otherwise the tenant header.
+func (a *App) requestTenant(r *http.Request, payloadTenant string) string {
+	if payloadTenant != "" {
+		return payloadTenant
+	}
+	return r.Header.Get(a.tenantHeader())
+}
+
+func (a *App) handleTenantMetrics(w http.ResponseWriter, r *http.Request) {
+	t, ok := a.lookupTenant(r.PathValue("tenant"))
+	if !ok {
+		http.Error(w, fmt.Sprintf("Unknown tenant %q", r.PathValue("tenant")), http.StatusNotFound)
+		return
+	}
+	t.metricsHandler.ServeHTTP(w, r)
+}
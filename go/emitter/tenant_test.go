@@ -0,0 +1,36 @@
+package emitter
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestTenantCap(t *testing.T) {
+	a := newTestApp(t, Config{
+		Limits:  LimitsConfig{MaxTenants: 2},
+		Tenants: TenantsConfig{Overrides: map[string]TenantOverride{"vip": {}}},
+	})
+	h := a.Handler("http")
+	for _, tc := range []struct {
+		tenant string
+		want   int
+	}{
+		{"a", http.StatusOK},
+		{"b", http.StatusOK},
+		{"a", http.StatusOK},
+		{"c", http.StatusTooManyRequests},
+		{"vip", http.StatusOK},
+		{"bad tenant", http.StatusBadRequest},
+	} {
+		w := postIncrement(h, "/p", `{"incrementBy":1}`, defaultTenantHeader, tc.tenant)
+		if w.Code != tc.want {
+			t.Errorf("tenant %q: status %d, want %d: %s", tc.tenant, w.Code, tc.want, w.Body)
+		}
+		if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Errorf("tenant %q: no Retry-After", tc.tenant)
+		}
+	}
+	if got, want := len(a.Tenants()), 3; got != want {
+		t.Errorf("%d tenants, want %d: %v", got, want, a.Tenants())
+	}
+}
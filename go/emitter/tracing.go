@@ -0,0 +1,96 @@
+package emitter
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// TracingConfig controls the spans of the increment handler, downstream
+// calls and worker ticks, which are exported to the OTLP endpoint with the
+// default tenant's resource.
+type TracingConfig struct {
+	// Disabled turns spans off; trace context is still propagated.
+	Disabled bool `json:"disabled,omitempty"`
+	// SampleRatio is the fraction of new traces sampled. Defaults to 1;
+	// continued traces follow their parent's decision.
+	SampleRatio *float64 `json:"sampleRatio,omitempty"`
+}
+
+func (c TracingConfig) validate() error {
+	if r := c.SampleRatio; r != nil && (*r < 0 || *r > 1) {
+		return fmt.Errorf("trace sample ratio must be between 0 and 1, got %g", *r)
+	}
+	return nil
+}
+
+// propagator carries trace context and baggage across HTTP calls.
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// initTracing sets up the TracerProvider. The default tenant must exist.
+func (a *App) initTracing(ctx context.Context) error {
+	cfg := a.cfg.Tracing
+	if cfg.Disabled {
+		return nil
+	}
+	exporter := a.spanExporter
+	if exporter == nil {
+		var err error
+		exporter, err = otlptracegrpc.New(ctx,
+			otlptracegrpc.WithEndpoint(a.otlpEndpoint),
+			otlptracegrpc.WithInsecure(),
+			otlptracegrpc.WithHeaders(a.exportHeaders("")),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	exporter = semconvSpanExporter{exporter, a.semconv()}
+	ratio := 1.0
+	if cfg.SampleRatio != nil {
+		ratio = *cfg.SampleRatio
+	}
+	a.tracerProvider = sdktrace.NewTracerProvider(
+		sdktrace.WithResource(a.defaultTenant.resource),
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	return nil
+}
+
+// TracerProvider returns the provider of the app's spans, a no-op one if
+// tracing is disabled.
+func (a *App) TracerProvider() trace.TracerProvider {
+	if a.tracerProvider == nil {
+		return noop.NewTracerProvider()
+	}
+	return a.tracerProvider
+}
+
+// Propagator returns the propagator used on incoming and outgoing calls.
+func (a *App) Propagator() propagation.TextMapPropagator {
+	return propagator
+}
+
+func (a *App) tracer() trace.Tracer {
+	return a.TracerProvider().Tracer(scopeName, trace.WithSchemaURL(a.semconv().schemaURL))
+}
+
+func (a *App) flushTraces(ctx context.Context) error {
+	if a.tracerProvider == nil {
+		return nil
+	}
+	return a.tracerProvider.ForceFlush(ctx)
+}
+
+func (a *App) shutdownTracing(ctx context.Context) error {
+	if a.tracerProvider == nil {
+		return nil
+	}
+	return a.tracerProvider.Shutdown(ctx)
+}
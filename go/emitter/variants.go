@@ -0,0 +1,432 @@
+package emitter
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Metric variants are metrics whose help, unit and type can be redeclared
+// while the app runs, to see how a backend resolves metadata conflicts.
+// Each lives in a named registry that is also its OTLP instrumentation
+// scope, so the same name can be a counter in one and a gauge in another.
+
+const (
+	variantCounter = "counter"
+	variantGauge   = "gauge"
+	// OpenMetrics-only types, available when OpenMetrics is enabled.
+	variantInfo           = "info"
+	variantStateSet       = "stateset"
+	variantGaugeHistogram = "gaugehistogram"
+)
+
+var variantTypes = []string{variantCounter, variantGauge, variantInfo, variantStateSet, variantGaugeHistogram}
+
+var (
+	validMetricName   = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	validLabelName    = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	validRegistryName = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+)
+
+// MetricVariant declares one metric variant.
+type MetricVariant struct {
+	// Registry names the Prometheus registry, served at
+	// /variants/{registry}/metrics, and the OTLP scope the variant is
+	// exported under. Empty means /metrics and the app's own scope.
+	Registry string `json:"registry,omitempty"`
+	// Name is the metric family name; info samples get an _info suffix.
+	Name string `json:"name"`
+	// Type is counter, gauge, info, stateset or gaugehistogram. Counters
+	// are exported as OTLP sums; the last three need OpenMetrics enabled.
+	Type string `json:"type"`
+	Help string `json:"help,omitempty"`
+	Unit string `json:"unit,omitempty"`
+	// Labels are put on every sample. They are the payload of an info.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Value is added to a counter and set on a gauge. Changing the type
+	// starts over from Value.
+	Value float64 `json:"value"`
+	// Created is a counter's created timestamp. It defaults to when the
+	// counter was first declared, or redeclared with a different type.
+	Created time.Time `json:"created,omitzero"`
+	// States lists a stateset's states and State is the one that is set.
+	States []string `json:"states,omitempty"`
+	State  string   `json:"state,omitempty"`
+	// Buckets are a gauge histogram's upper bounds, defaulting to
+	// prometheus.DefBuckets, and Observations its current population.
+	Buckets      []float64 `json:"buckets,omitempty"`
+	Observations []float64 `json:"observations,omitempty"`
+}
+
+func (v MetricVariant) validate() error {
+	if v.Registry != "" && !validRegistryName.MatchString(v.Registry) {
+		return fmt.Errorf("invalid registry name %q", v.Registry)
+	}
+	if !validMetricName.MatchString(v.Name) {
+		return fmt.Errorf("invalid metric name %q", v.Name)
+	}
+	if !slices.Contains(variantTypes, v.Type) {
+		return fmt.Errorf("unknown variant type %q, expected one of %v", v.Type, variantTypes)
+	}
+	for name := range v.Labels {
+		if !validLabelName.MatchString(name) || name == "le" || name == v.Name {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+	switch v.Type {
+	case variantCounter:
+		if v.Value < 0 {
+			return fmt.Errorf("counter %s cannot decrease", v.Name)
+		}
+	case variantStateSet:
+		if len(v.States) == 0 {
+			return fmt.Errorf("stateset %s needs states", v.Name)
+		}
+		if v.State != "" && !slices.Contains(v.States, v.State) {
+			return fmt.Errorf("state %q is not one of %v", v.State, v.States)
+		}
+	case variantGaugeHistogram:
+		if !slices.IsSorted(v.Buckets) {
+			return fmt.Errorf("gauge histogram %s buckets must be sorted", v.Name)
+		}
+	}
+	return nil
+}
+
+// isAppMetric reports whether name is one of the app's own metrics, which
+// variants in the unnamed registry share /metrics and the OTLP scope with.
+// Counters match with or without their _total suffix, as OpenMetrics adds
+// it to counter samples.
+func (a *App) isAppMetric(name string) bool {
+	for _, m := range a.Metrics() {
+		if name == m.Name || name == strings.TrimSuffix(m.Name, "_total") {
+			return true
+		}
+	}
+	return false
+}
+
+func openMetricsOnly(typ string) bool {
+	return typ == variantInfo || typ == variantStateSet || typ == variantGaugeHistogram
+}
+
+// sampleName is the name the variant's samples are exposed under in the
+// classic formats.
+func (v *MetricVariant) sampleName() string {
+	if v.Type == variantInfo {
+		return v.Name + "_info"
+	}
+	return v.Name
+}
+
+type variantKey struct {
+	registry string
+	name     string
+}
+
+// variant is immutable once declared; redeclaring replaces it.
+type variant struct {
+	MetricVariant
+	otlp metric.Registration
+}
+
+type variantSet struct {
+	mu       sync.Mutex
+	variants map[variantKey]*variant
+}
+
+// DeclareVariant declares v, replacing any variant with the same registry
+// and name, and returns it as now exposed. Variants in the unnamed registry
+// cannot reuse the name of one of the app's own metrics.
+func (a *App) DeclareVariant(v MetricVariant) (MetricVariant, error) {
+	if err := v.validate(); err != nil {
+		return v, err
+	}
+	if openMetricsOnly(v.Type) && !a.openMetrics {
+		return v, fmt.Errorf("%s variants need OpenMetrics enabled", v.Type)
+	}
+	if v.Registry == "" && a.isAppMetric(v.sampleName()) {
+		return v, fmt.Errorf("variant %s collides with the app's own metric of that name", v.sampleName())
+	}
+	if v.Type == variantGaugeHistogram && v.Buckets == nil {
+		v.Buckets = prometheus.DefBuckets
+	}
+	vs := &a.variants
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	key := variantKey{v.Registry, v.Name}
+	if old, ok := vs.variants[key]; ok {
+		if old.Type == variantCounter && v.Type == variantCounter {
+			v.Value += old.Value
+			if v.Created.IsZero() {
+				v.Created = old.Created
+			}
+		}
+		if err := old.unregister(); err != nil {
+			return v, err
+		}
+		delete(vs.variants, key)
+	}
+	if v.Type != variantCounter {
+		v.Created = time.Time{}
+	} else if v.Created.IsZero() {
+		v.Created = time.Now()
+	}
+	nv := &variant{MetricVariant: v}
+	var err error
+	nv.otlp, err = a.registerVariantInstrument(nv)
+	if err != nil {
+		return v, err
+	}
+	if vs.variants == nil {
+		vs.variants = make(map[variantKey]*variant)
+	}
+	vs.variants[key] = nv
+	log.Printf("Declared %s %s in registry %q with help %q and unit %q", v.Type, v.Name, v.Registry, v.Help, v.Unit)
+	return v, nil
+}
+
+// RemoveVariant removes a variant and reports whether there was one.
+func (a *App) RemoveVariant(registry, name string) bool {
+	vs := &a.variants
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	key := variantKey{registry, name}
+	v, ok := vs.variants[key]
+	if !ok {
+		return false
+	}
+	_ = v.unregister()
+	delete(vs.variants, key)
+	return true
+}
+
+// Variants returns the declared variants sorted by registry and name.
+func (a *App) Variants() []MetricVariant {
+	vs := &a.variants
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+	out := make([]MetricVariant, 0, len(vs.variants))
+	for _, v := range vs.variants {
+		out = append(out, v.MetricVariant)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Registry != out[j].Registry {
+			return out[i].Registry < out[j].Registry
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// registerVariantInstrument creates an observable instrument for v on the
+// default tenant's MeterProvider. The SDK cannot delete instruments, so a
+// replaced variant stops being exported by unregistering its callback.
+// Infos and statesets become gauges, one per state for a stateset. OTLP has
+// no gauge histogram, so those are only exposed to Prometheus.
+func (a *App) registerVariantInstrument(v *variant) (metric.Registration, error) {
+	scope := v.Registry
+	if scope == "" {
+		scope = scopeName
+	}
+	meter := a.defaultTenant.meterProvider.Meter(scope, metric.WithInstrumentationVersion("v1.0.0"))
+	var attrs []attribute.KeyValue
+	for k, val := range v.Labels {
+		attrs = append(attrs, attribute.String(k, val))
+	}
+	var inst metric.Float64Observable
+	var observe func(metric.Observer)
+	var err error
+	switch v.Type {
+	case variantCounter:
+		inst, err = meter.Float64ObservableCounter(v.Name, metric.WithDescription(v.Help), metric.WithUnit(v.Unit))
+		observe = func(o metric.Observer) { o.ObserveFloat64(inst, v.Value, metric.WithAttributes(attrs...)) }
+	case variantGauge:
+		inst, err = meter.Float64ObservableGauge(v.Name, metric.WithDescription(v.Help), metric.WithUnit(v.Unit))
+		observe = func(o metric.Observer) { o.ObserveFloat64(inst, v.Value, metric.WithAttributes(attrs...)) }
+	case variantInfo:
+		inst, err = meter.Float64ObservableGauge(v.Name, metric.WithDescription(v.Help))
+		observe = func(o metric.Observer) { o.ObserveFloat64(inst, 1, metric.WithAttributes(attrs...)) }
+	case variantStateSet:
+		inst, err = meter.Float64ObservableGauge(v.Name, metric.WithDescription(v.Help))
+		observe = func(o metric.Observer) {
+			for _, state := range v.States {
+				o.ObserveFloat64(inst, v.stateValue(state), metric.WithAttributes(append(slices.Clip(attrs), attribute.String(v.Name, state))...))
+			}
+		}
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		observe(o)
+		return nil
+	}, inst)
+}
+
+func (v *variant) unregister() error {
+	if v.otlp == nil {
+		return nil
+	}
+	return v.otlp.Unregister()
+}
+
+func (v *MetricVariant) stateValue(state string) float64 {
+	if state == v.State {
+		return 1
+	}
+	return 0
+}
+
+// variantGatherer gathers the variants of one registry. Building the metric
+// families by hand is what lets the type and help of a name change between
+// scrapes, which a prometheus.Registry refuses.
+type variantGatherer struct {
+	set      *variantSet
+	registry string
+}
+
+func (g variantGatherer) Gather() ([]*dto.MetricFamily, error) {
+	g.set.mu.Lock()
+	defer g.set.mu.Unlock()
+	var mfs []*dto.MetricFamily
+	for _, v := range g.set.variants {
+		if v.Registry == g.registry {
+			mfs = append(mfs, v.metricFamily())
+		}
+	}
+	sort.Slice(mfs, func(i, j int) bool { return mfs[i].GetName() < mfs[j].GetName() })
+	return mfs, nil
+}
+
+// metricFamily renders v for the classic formats: an info is a gauge of 1,
+// a stateset a gauge per state and a gauge histogram a histogram.
+func (v *variant) metricFamily() *dto.MetricFamily {
+	mf := &dto.MetricFamily{Name: proto.String(v.sampleName())}
+	if v.Help != "" {
+		mf.Help = proto.String(v.Help)
+	}
+	if v.Unit != "" {
+		mf.Unit = proto.String(v.Unit)
+	}
+	switch v.Type {
+	case variantCounter:
+		mf.Type = dto.MetricType_COUNTER.Enum()
+		mf.Metric = []*dto.Metric{{Label: v.labelPairs(), Counter: &dto.Counter{
+			Value:            proto.Float64(v.Value),
+			CreatedTimestamp: timestamppb.New(v.Created),
+		}}}
+	case variantGauge:
+		mf.Type = dto.MetricType_GAUGE.Enum()
+		mf.Metric = []*dto.Metric{{Label: v.labelPairs(), Gauge: &dto.Gauge{Value: proto.Float64(v.Value)}}}
+	case variantInfo:
+		mf.Type = dto.MetricType_GAUGE.Enum()
+		mf.Metric = []*dto.Metric{{Label: v.labelPairs(), Gauge: &dto.Gauge{Value: proto.Float64(1)}}}
+	case variantStateSet:
+		mf.Type = dto.MetricType_GAUGE.Enum()
+		for _, state := range v.States {
+			mf.Metric = append(mf.Metric, &dto.Metric{
+				Label: v.labelPairs(v.Name, state),
+				Gauge: &dto.Gauge{Value: proto.Float64(v.stateValue(state))},
+			})
+		}
+	case variantGaugeHistogram:
+		mf.Type = dto.MetricType_HISTOGRAM.Enum()
+		count, sum, buckets := v.histogram()
+		h := &dto.Histogram{SampleCount: proto.Uint64(count), SampleSum: proto.Float64(sum)}
+		for i, upper := range v.Buckets {
+			h.Bucket = append(h.Bucket, &dto.Bucket{UpperBound: proto.Float64(upper), CumulativeCount: proto.Uint64(buckets[i])})
+		}
+		mf.Metric = []*dto.Metric{{Label: v.labelPairs(), Histogram: h}}
+	}
+	return mf
+}
+
+// labelPairs returns v's labels plus the extra name/value pairs, sorted.
+func (v *MetricVariant) labelPairs(extra ...string) []*dto.LabelPair {
+	var pairs []*dto.LabelPair
+	for k, val := range v.Labels {
+		pairs = append(pairs, &dto.LabelPair{Name: proto.String(k), Value: proto.String(val)})
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, &dto.LabelPair{Name: proto.String(extra[i]), Value: proto.String(extra[i+1])})
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].GetName() < pairs[j].GetName() })
+	return pairs
+}
+
+// histogram returns the count, sum and cumulative bucket counts of a gauge
+// histogram's observations.
+func (v *MetricVariant) histogram() (count uint64, sum float64, buckets []uint64) {
+	buckets = make([]uint64, len(v.Buckets))
+	for _, o := range v.Observations {
+		sum += o
+		for i, upper := range v.Buckets {
+			if o <= upper {
+				buckets[i]++
+			}
+		}
+	}
+	return uint64(len(v.Observations)), sum, buckets
+}
+
+// variantCreated returns the created timestamp of a counter variant.
+func (a *App) variantCreated(registry, name string) (time.Time, bool) {
+	a.variants.mu.Lock()
+	defer a.variants.mu.Unlock()
+	v, ok := a.variants.variants[variantKey{registry, name}]
+	if !ok || v.Type != variantCounter {
+		return time.Time{}, false
+	}
+	return v.Created, true
+}
+
+// registryVariants returns the variants of registry keyed by their classic
+// sample name.
+func (a *App) registryVariants(registry string) map[string]MetricVariant {
+	a.variants.mu.Lock()
+	defer a.variants.mu.Unlock()
+	out := make(map[string]MetricVariant)
+	for _, v := range a.variants.variants {
+		if v.Registry == registry {
+			out[v.sampleName()] = v.MetricVariant
+		}
+	}
+	return out
+}
+
+func (a *App) hasVariantRegistry(registry string) bool {
+	a.variants.mu.Lock()
+	defer a.variants.mu.Unlock()
+	for key := range a.variants.variants {
+		if key.registry == registry {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *App) handleVariantMetrics(w http.ResponseWriter, r *http.Request) {
+	registry := r.PathValue("registry")
+	if !a.hasVariantRegistry(registry) {
+		http.Error(w, fmt.Sprintf("No variants in registry %q", registry), http.StatusNotFound)
+		return
+	}
+	a.recordScrapes(a.metricsHandler(variantGatherer{&a.variants, registry}, registry)).ServeHTTP(w, r)
+}
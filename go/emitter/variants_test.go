@@ -0,0 +1,37 @@
+package emitter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestDeclareVariantAppMetricNames checks variants in the unnamed registry
+// cannot take the name of a metric the app serves from /metrics itself.
+func TestDeclareVariantAppMetricNames(t *testing.T) {
+	a := newTestApp(t, Config{})
+	postIncrement(a.Handler("http"), "/a", `{"incrementBy":1}`)
+	for _, v := range []MetricVariant{
+		{Name: promCounterName, Type: variantGauge},
+		{Name: "erik_prom_path_increment_count", Type: variantCounter},
+		{Name: sampleTimestampName, Type: variantCounter},
+		{Name: otlpSumCounterName, Type: variantGauge},
+	} {
+		if _, err := a.DeclareVariant(v); err == nil {
+			t.Errorf("declared %s %s in the unnamed registry", v.Type, v.Name)
+		}
+	}
+	for _, v := range []MetricVariant{
+		{Name: promCounterName, Type: variantGauge, Registry: "other"},
+		{Name: "erik_prom_path_increment", Type: variantGauge},
+	} {
+		if _, err := a.DeclareVariant(v); err != nil {
+			t.Errorf("declaring %s in registry %q: %v", v.Name, v.Registry, err)
+		}
+	}
+	w := httptest.NewRecorder()
+	a.Handler("metrics").ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("GET /metrics: %d %s", w.Code, w.Body)
+	}
+}
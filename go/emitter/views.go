@@ -0,0 +1,183 @@
+package emitter
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// Reader modes for SDKConfig.
+const (
+	ReaderPeriodic = "periodic"
+	ReaderManual   = "manual"
+)
+
+// SDKConfig tunes every tenant's MeterProvider.
+type SDKConfig struct {
+	// Reader is periodic, the default, exporting every export interval,
+	// or manual, exporting only on POST /flush.
+	Reader string `json:"reader,omitempty"`
+	// Views are applied in order; see sdkmetric.NewView.
+	Views []ViewConfig `json:"views,omitempty"`
+	// CardinalityLimit caps the attribute sets per instrument; further
+	// sets are folded into one overflow point. The SDK only supports a
+	// limit for the whole MeterProvider, not per view. 0 means no limit.
+	CardinalityLimit int `json:"cardinalityLimit,omitempty"`
+}
+
+// ViewConfig declares one sdkmetric.View.
+type ViewConfig struct {
+	// Instrument selects instruments by name and may use the * and ?
+	// wildcards, unless the view renames them.
+	Instrument string `json:"instrument"`
+	// Kind further restricts the match to counter, updowncounter,
+	// histogram, gauge, observablecounter, observableupdowncounter or
+	// observablegauge instruments.
+	Kind string `json:"kind,omitempty"`
+	// Scope further restricts the match to one instrumentation scope.
+	Scope string `json:"scope,omitempty"`
+
+	// Name and Description replace the instrument's. Renamed counters
+	// still get their created timestamps aligned on export.
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	// AllowAttributes keeps only these attribute keys; DenyAttributes
+	// drops these. At most one may be set.
+	AllowAttributes []string `json:"allowAttributes,omitempty"`
+	DenyAttributes  []string `json:"denyAttributes,omitempty"`
+	// Aggregation is default, drop, sum, lastvalue, explicit or
+	// exponential.
+	Aggregation string `json:"aggregation,omitempty"`
+	// Buckets are the explicit histogram boundaries. Empty means the SDK
+	// defaults.
+	Buckets []float64 `json:"buckets,omitempty"`
+	// MaxSize and MaxScale shape the exponential histogram, defaulting to
+	// 160 and 20. MaxSize must be positive and MaxScale between -10 and 20.
+	MaxSize  int32 `json:"maxSize,omitempty"`
+	MaxScale int32 `json:"maxScale,omitempty"`
+	// NoMinMax stops histograms recording min and max.
+	NoMinMax bool `json:"noMinMax,omitempty"`
+}
+
+var instrumentKinds = map[string]sdkmetric.InstrumentKind{
+	"counter":                 sdkmetric.InstrumentKindCounter,
+	"updowncounter":           sdkmetric.InstrumentKindUpDownCounter,
+	"histogram":               sdkmetric.InstrumentKindHistogram,
+	"gauge":                   sdkmetric.InstrumentKindGauge,
+	"observablecounter":       sdkmetric.InstrumentKindObservableCounter,
+	"observableupdowncounter": sdkmetric.InstrumentKindObservableUpDownCounter,
+	"observablegauge":         sdkmetric.InstrumentKindObservableGauge,
+}
+
+var viewAggregations = []string{"", "default", "drop", "sum", "lastvalue", "explicit", "exponential"}
+
+func (v ViewConfig) validate() error {
+	if v.Instrument == "" {
+		return fmt.Errorf("view needs an instrument")
+	}
+	if _, ok := instrumentKinds[v.Kind]; v.Kind != "" && !ok {
+		return fmt.Errorf("view for %s: unknown instrument kind %q", v.Instrument, v.Kind)
+	}
+	if v.Name != "" && strings.ContainsAny(v.Instrument, "*?") {
+		return fmt.Errorf("view for %s: cannot rename instruments matched by a wildcard", v.Instrument)
+	}
+	if len(v.AllowAttributes) > 0 && len(v.DenyAttributes) > 0 {
+		return fmt.Errorf("view for %s: set allowAttributes or denyAttributes, not both", v.Instrument)
+	}
+	if !slices.Contains(viewAggregations, v.Aggregation) {
+		return fmt.Errorf("view for %s: unknown aggregation %q", v.Instrument, v.Aggregation)
+	}
+	if !slices.IsSorted(v.Buckets) {
+		return fmt.Errorf("view for %s: buckets must be sorted", v.Instrument)
+	}
+	if v.MaxSize < 0 {
+		return fmt.Errorf("view for %s: maxSize must be positive, got %d", v.Instrument, v.MaxSize)
+	}
+	if v.MaxScale < -10 || v.MaxScale > 20 {
+		return fmt.Errorf("view for %s: maxScale must be between -10 and 20, got %d", v.Instrument, v.MaxScale)
+	}
+	return nil
+}
+
+func (v ViewConfig) view() sdkmetric.View {
+	criteria := sdkmetric.Instrument{
+		Name:  v.Instrument,
+		Kind:  instrumentKinds[v.Kind],
+		Scope: instrumentation.Scope{Name: v.Scope},
+	}
+	mask := sdkmetric.Stream{
+		Name:        v.Name,
+		Description: v.Description,
+		Aggregation: v.aggregation(),
+	}
+	if len(v.AllowAttributes) > 0 {
+		mask.AttributeFilter = attribute.NewAllowKeysFilter(attributeKeys(v.AllowAttributes)...)
+	}
+	if len(v.DenyAttributes) > 0 {
+		mask.AttributeFilter = attribute.NewDenyKeysFilter(attributeKeys(v.DenyAttributes)...)
+	}
+	return sdkmetric.NewView(criteria, mask)
+}
+
+func (v ViewConfig) aggregation() sdkmetric.Aggregation {
+	switch v.Aggregation {
+	case "drop":
+		return sdkmetric.AggregationDrop{}
+	case "sum":
+		return sdkmetric.AggregationSum{}
+	case "lastvalue":
+		return sdkmetric.AggregationLastValue{}
+	case "explicit":
+		agg := sdkmetric.AggregationExplicitBucketHistogram{Boundaries: v.Buckets, NoMinMax: v.NoMinMax}
+		if len(agg.Boundaries) == 0 {
+			agg.Boundaries = []float64{0, 5, 10, 25, 50, 75, 100, 250, 500, 750, 1000, 2500, 5000, 7500, 10000}
+		}
+		return agg
+	case "exponential":
+		agg := sdkmetric.AggregationBase2ExponentialHistogram{MaxSize: v.MaxSize, MaxScale: v.MaxScale, NoMinMax: v.NoMinMax}
+		if agg.MaxSize == 0 {
+			agg.MaxSize = 160
+		}
+		if agg.MaxScale == 0 {
+			agg.MaxScale = 20
+		}
+		return agg
+	}
+	return nil
+}
+
+func attributeKeys(names []string) []attribute.Key {
+	keys := make([]attribute.Key, len(names))
+	for i, n := range names {
+		keys[i] = attribute.Key(n)
+	}
+	return keys
+}
+
+// instrumentName returns the name of the instrument the stream called name
+// in scope was exported from, undoing views that rename instruments. Those
+// views match a single instrument, so the rename can be reversed.
+func (c SDKConfig) instrumentName(scope, name string) string {
+	for _, v := range c.Views {
+		if v.Name == name && (v.Scope == "" || v.Scope == scope) {
+			return v.Instrument
+		}
+	}
+	return name
+}
+
+// meterProviderOptions returns the views and limits from the SDK config.
+func (c SDKConfig) meterProviderOptions() []sdkmetric.Option {
+	var opts []sdkmetric.Option
+	for _, v := range c.Views {
+		opts = append(opts, sdkmetric.WithView(v.view()))
+	}
+	if c.CardinalityLimit > 0 {
+		opts = append(opts, sdkmetric.WithCardinalityLimit(c.CardinalityLimit))
+	}
+	return opts
+}
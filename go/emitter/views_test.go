@@ -0,0 +1,74 @@
+package emitter
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+func TestViewValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		view ViewConfig
+		ok   bool
+	}{
+		{"plain", ViewConfig{Instrument: "x", Aggregation: "sum"}, true},
+		{"no instrument", ViewConfig{}, false},
+		{"unknown kind", ViewConfig{Instrument: "x", Kind: "meter"}, false},
+		{"renamed wildcard", ViewConfig{Instrument: "x*", Name: "y"}, false},
+		{"allow and deny", ViewConfig{Instrument: "x", AllowAttributes: []string{"a"}, DenyAttributes: []string{"b"}}, false},
+		{"unknown aggregation", ViewConfig{Instrument: "x", Aggregation: "avg"}, false},
+		{"unsorted buckets", ViewConfig{Instrument: "x", Aggregation: "explicit", Buckets: []float64{2, 1}}, false},
+		{"exponential bounds", ViewConfig{Instrument: "x", Aggregation: "exponential", MaxSize: 1, MaxScale: -10}, true},
+		{"negative max size", ViewConfig{Instrument: "x", Aggregation: "exponential", MaxSize: -1}, false},
+		{"max scale too low", ViewConfig{Instrument: "x", Aggregation: "exponential", MaxScale: -11}, false},
+		{"max scale too high", ViewConfig{Instrument: "x", Aggregation: "exponential", MaxScale: 21}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.view.validate(); (err == nil) != tc.ok {
+				t.Errorf("validate() = %v, want ok %t", err, tc.ok)
+			}
+		})
+	}
+}
+
+func TestViewExplicitBuckets(t *testing.T) {
+	defaults := ViewConfig{Aggregation: "explicit"}.aggregation().(sdkmetric.AggregationExplicitBucketHistogram).Boundaries
+	for _, tc := range []struct {
+		name    string
+		buckets []float64
+		want    []float64
+	}{
+		{"unset", nil, defaults},
+		{"empty", []float64{}, defaults},
+		{"set", []float64{1, 2}, []float64{1, 2}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			agg := ViewConfig{Aggregation: "explicit", Buckets: tc.buckets}.aggregation().(sdkmetric.AggregationExplicitBucketHistogram)
+			if !slices.Equal(agg.Boundaries, tc.want) {
+				t.Errorf("boundaries = %v, want %v", agg.Boundaries, tc.want)
+			}
+		})
+	}
+}
+
+func TestViewsApplied(t *testing.T) {
+	exp := &captureExporter{}
+	a := newTestApp(t, Config{SDK: SDKConfig{Reader: ReaderManual, Views: []ViewConfig{
+		{Instrument: otlpSumCounterName, Scope: scopeName, Description: "described", DenyAttributes: []string{"path"}},
+	}}}, WithExporter(exp))
+	for _, p := range []string{"/a", "/b"} {
+		if err := a.Increment(p, IncrementRequest{IncrementBy: 1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	points := exp.int64Sum(t, otlpSumCounterName)
+	if len(points) != 1 || points[0].Value != 2 || points[0].Attributes.Len() != 0 {
+		t.Errorf("points = %+v, want one point of 2 without attributes", points)
+	}
+}
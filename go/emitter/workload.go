@@ -0,0 +1,254 @@
+package emitter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Latency distributions a PathProfile can use.
+const (
+	LatencyConstant    = "constant"
+	LatencyUniform     = "uniform"
+	LatencyNormal      = "normal"
+	LatencyLogNormal   = "lognormal"
+	LatencyExponential = "exponential"
+)
+
+var latencyDistributions = []string{LatencyConstant, LatencyUniform, LatencyNormal, LatencyLogNormal, LatencyExponential}
+
+// z99 is the 99th percentile of the standard normal distribution.
+const z99 = 2.3263478740408408
+
+// maxResponseBytes bounds the size responses can be padded to.
+const maxResponseBytes = 16 << 20
+
+// statusClientClosedRequest is the status recorded for requests whose
+// client went away before the simulation finished, as nginx logs them.
+const statusClientClosedRequest = 499
+
+// WorkloadConfig shapes how the increment handler on the "http" listener
+// responds, so the otelhttp server metrics show realistic rate, error and
+// duration signals, as do its server spans unless tracing is disabled.
+// Requests to paths no profile matches return 200 at once.
+type WorkloadConfig struct {
+	// Profiles are tried in order; the first whose Path matches is used.
+	// Change them with POST /api/workload.
+	Profiles []PathProfile `json:"profiles"`
+}
+
+// PathProfile is the simulated behaviour of the paths matching Path.
+type PathProfile struct {
+	// Path is a path.Match pattern such as /checkout or /api/*.
+	Path    string         `json:"path"`
+	Latency LatencyProfile `json:"latency,omitzero"`
+	// ErrorRate is the fraction of requests, from 0 to 1, that fail
+	// without being applied or creating their tenant.
+	ErrorRate float64 `json:"errorRate,omitempty"`
+	// ErrorStatuses weighs the status codes failures get. Defaults to
+	// {"500": 1}.
+	ErrorStatuses map[int]float64 `json:"errorStatuses,omitempty"`
+	// ResponseBytes pads successful responses to at least this size, or to
+	// a uniform size up to MaxResponseBytes if that is larger. Both are at
+	// most 16 MiB.
+	ResponseBytes    int `json:"responseBytes,omitempty"`
+	MaxResponseBytes int `json:"maxResponseBytes,omitempty"`
+}
+
+// LatencyProfile is how long the handler takes before responding, in
+// milliseconds. Constant and exponential use MeanMs, uniform MinMs to
+// MaxMs, normal MeanMs and StddevMs, and lognormal MedianMs and P99Ms.
+// MaxMs also caps every other distribution.
+type LatencyProfile struct {
+	// Distribution defaults to constant.
+	Distribution string  `json:"distribution,omitempty"`
+	MeanMs       float64 `json:"meanMs,omitempty"`
+	StddevMs     float64 `json:"stddevMs,omitempty"`
+	MedianMs     float64 `json:"medianMs,omitempty"`
+	P99Ms        float64 `json:"p99Ms,omitempty"`
+	MinMs        float64 `json:"minMs,omitempty"`
+	MaxMs        float64 `json:"maxMs,omitempty"`
+}
+
+func (c WorkloadConfig) validate() error {
+	for _, p := range c.Profiles {
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("workload profile %q: %w", p.Path, err)
+		}
+	}
+	return nil
+}
+
+func (p PathProfile) validate() error {
+	if !strings.HasPrefix(p.Path, "/") {
+		return fmt.Errorf("path must start with /")
+	}
+	if _, err := path.Match(p.Path, ""); err != nil {
+		return err
+	}
+	if p.ErrorRate < 0 || p.ErrorRate > 1 {
+		return fmt.Errorf("error rate must be between 0 and 1, got %g", p.ErrorRate)
+	}
+	for code, weight := range p.ErrorStatuses {
+		if code < 400 || code > 599 {
+			return fmt.Errorf("error status must be 4xx or 5xx, got %d", code)
+		}
+		if weight < 0 {
+			return fmt.Errorf("weight of status %d must not be negative, got %g", code, weight)
+		}
+	}
+	if p.ResponseBytes < 0 || p.MaxResponseBytes < 0 {
+		return fmt.Errorf("response bytes must not be negative")
+	}
+	if p.ResponseBytes > maxResponseBytes || p.MaxResponseBytes > maxResponseBytes {
+		return fmt.Errorf("response bytes must be at most %d", maxResponseBytes)
+	}
+	return p.Latency.validate()
+}
+
+func (l LatencyProfile) validate() error {
+	if l.Distribution != "" && !slices.Contains(latencyDistributions, l.Distribution) {
+		return fmt.Errorf("unknown latency distribution %q, expected one of %v", l.Distribution, latencyDistributions)
+	}
+	for _, v := range []float64{l.MeanMs, l.StddevMs, l.MedianMs, l.P99Ms, l.MinMs, l.MaxMs} {
+		if v < 0 {
+			return fmt.Errorf("latencies must not be negative")
+		}
+	}
+	switch l.Distribution {
+	case LatencyUniform:
+		if l.MaxMs < l.MinMs {
+			return fmt.Errorf("uniform latency needs minMs <= maxMs, got %g and %g", l.MinMs, l.MaxMs)
+		}
+	case LatencyLogNormal:
+		if l.MedianMs <= 0 || l.P99Ms < l.MedianMs {
+			return fmt.Errorf("lognormal latency needs 0 < medianMs <= p99Ms, got %g and %g", l.MedianMs, l.P99Ms)
+		}
+	}
+	return nil
+}
+
+// sample draws a latency.
+func (l LatencyProfile) sample() time.Duration {
+	var ms float64
+	switch l.Distribution {
+	case LatencyUniform:
+		ms = l.MinMs + rand.Float64()*(l.MaxMs-l.MinMs)
+	case LatencyNormal:
+		ms = l.MeanMs + rand.NormFloat64()*l.StddevMs
+	case LatencyLogNormal:
+		mu := math.Log(l.MedianMs)
+		sigma := (math.Log(l.P99Ms) - mu) / z99
+		ms = math.Exp(mu + rand.NormFloat64()*sigma)
+	case LatencyExponential:
+		ms = rand.ExpFloat64() * l.MeanMs
+	default:
+		ms = l.MeanMs
+	}
+	if l.MaxMs > 0 {
+		ms = min(ms, l.MaxMs)
+	}
+	return time.Duration(max(ms, 0) * float64(time.Millisecond))
+}
+
+// errorStatus draws the status of a failed request.
+func (p PathProfile) errorStatus() int {
+	var total float64
+	for _, w := range p.ErrorStatuses {
+		total += w
+	}
+	if total == 0 {
+		return http.StatusInternalServerError
+	}
+	// Walk the codes in order so the draw does not depend on map order.
+	codes := make([]int, 0, len(p.ErrorStatuses))
+	for code := range p.ErrorStatuses {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	x := rand.Float64() * total
+	for _, code := range codes {
+		if x -= p.ErrorStatuses[code]; x < 0 {
+			return code
+		}
+	}
+	return codes[len(codes)-1]
+}
+
+// responseSize draws the size successful responses are padded to.
+func (p PathProfile) responseSize() int {
+	if p.MaxResponseBytes > p.ResponseBytes {
+		return p.ResponseBytes + rand.IntN(p.MaxResponseBytes-p.ResponseBytes+1)
+	}
+	return p.ResponseBytes
+}
+
+// workloadProfile returns the profile for path, if any.
+func (a *App) workloadProfile(urlPath string) (PathProfile, bool) {
+	cfg := a.workload.Load()
+	if cfg == nil {
+		return PathProfile{}, false
+	}
+	for _, p := range cfg.Profiles {
+		if ok, _ := path.Match(p.Path, urlPath); ok {
+			return p, true
+		}
+	}
+	return PathProfile{}, false
+}
+
+// SetWorkload replaces the path profiles and returns the settings now in
+// effect.
+func (a *App) SetWorkload(cfg WorkloadConfig) (WorkloadConfig, error) {
+	if err := cfg.validate(); err != nil {
+		return cfg, err
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = []PathProfile{}
+	}
+	a.workload.Store(&cfg)
+	return cfg, nil
+}
+
+// Workload returns the path profiles.
+func (a *App) Workload() WorkloadConfig {
+	if cfg := a.workload.Load(); cfg != nil {
+		return *cfg
+	}
+	return WorkloadConfig{Profiles: []PathProfile{}}
+}
+
+// simulate sleeps for the profile's latency and reports the status the
+// request fails with, or 0 if it succeeds. It returns ctx's error if the
+// client goes away first.
+func (p PathProfile) simulate(ctx context.Context) (int, error) {
+	if d := p.Latency.sample(); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+	if p.ErrorRate > 0 && rand.Float64() < p.ErrorRate {
+		return p.errorStatus(), nil
+	}
+	return 0, nil
+}
+
+// spaces reads as an endless run of spaces, which pad responses.
+type spaces struct{}
+
+func (spaces) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = ' '
+	}
+	return len(p), nil
+}
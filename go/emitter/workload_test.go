@@ -0,0 +1,87 @@
+package emitter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestWorkloadSimulation(t *testing.T) {
+	spans := tracetest.NewInMemoryExporter()
+	a := newTestApp(t, Config{Workload: WorkloadConfig{Profiles: []PathProfile{
+		{Path: "/fail", ErrorRate: 1, ErrorStatuses: map[int]float64{503: 1}},
+		{Path: "/slow", Latency: LatencyProfile{MeanMs: 20}, ResponseBytes: 100},
+	}}}, WithSpanExporter(spans))
+	h := a.Handler("http")
+
+	if w := postIncrement(h, "/fail", `{"incrementBy":1}`, defaultTenantHeader, "simulated"); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("POST /fail: %d, want 503", w.Code)
+	}
+	if slices.Contains(a.Tenants(), "simulated") {
+		t.Error("a simulated failure created its tenant")
+	}
+	if _, ok := a.ledger.state(seriesKey{"", "/fail"}); ok {
+		t.Error("a simulated failure was applied")
+	}
+
+	start := time.Now()
+	w := postIncrement(h, "/slow", `{"incrementBy":1}`)
+	if took := time.Since(start); w.Code != http.StatusOK || took < 20*time.Millisecond || w.Body.Len() != 100 {
+		t.Errorf("POST /slow: %d with %d bytes after %s, want 200 with 100 bytes after 20ms", w.Code, w.Body.Len(), took)
+	}
+
+	if err := a.tracerProvider.ForceFlush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	got := spans.GetSpans()
+	if len(got) != 2 {
+		t.Fatalf("%d spans, want 2", len(got))
+	}
+	if got[0].Status.Code != codes.Error {
+		t.Errorf("failed request's span has status %v, want Error", got[0].Status.Code)
+	}
+	if d := got[1].EndTime.Sub(got[1].StartTime); d < 20*time.Millisecond {
+		t.Errorf("slow request's span took %s, want at least 20ms", d)
+	}
+}
+
+// TestWorkloadClientGone checks a request whose client leaves during the
+// simulated latency is not applied.
+func TestWorkloadClientGone(t *testing.T) {
+	a := newTestApp(t, Config{Workload: WorkloadConfig{Profiles: []PathProfile{
+		{Path: "/slow", Latency: LatencyProfile{MeanMs: 10000}},
+	}}})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequestWithContext(ctx, http.MethodPost, "/slow", strings.NewReader(`{"incrementBy":1}`))
+	w := httptest.NewRecorder()
+	a.Handler("http").ServeHTTP(w, req)
+	if w.Code != statusClientClosedRequest {
+		t.Errorf("status %d, want %d", w.Code, statusClientClosedRequest)
+	}
+	if _, ok := a.ledger.state(seriesKey{"", "/slow"}); ok {
+		t.Error("an abandoned request was applied")
+	}
+}
+
+func TestWorkloadValidate(t *testing.T) {
+	for _, p := range []PathProfile{
+		{Path: "/a", ResponseBytes: -1},
+		{Path: "/a", ResponseBytes: maxResponseBytes + 1},
+		{Path: "/a", MaxResponseBytes: 1 << 40},
+	} {
+		if err := (WorkloadConfig{Profiles: []PathProfile{p}}).validate(); err == nil {
+			t.Errorf("%+v is valid", p)
+		}
+	}
+	if err := (WorkloadConfig{Profiles: []PathProfile{{Path: "/a", ResponseBytes: 1, MaxResponseBytes: maxResponseBytes}}}).validate(); err != nil {
+		t.Error(err)
+	}
+}